@@ -0,0 +1,260 @@
+// Package archive implements a streaming file format for the contents of a cadata.Store.
+//
+// An archive is a header, followed by length-prefixed blobs in ascending order by ID,
+// followed by an end marker, and then an optional index.
+//
+//	header:  magic (8 bytes) | version (1 byte)
+//	blob:    length (4 bytes) | id (32 bytes) | data (length bytes)
+//	end:     0xFFFFFFFF (4 bytes)
+//	index:   (id (32 bytes) | offset (8 bytes) | length (4 bytes))* | index offset (8 bytes) | index magic (8 bytes)
+//
+// All integers are big endian.
+// The index allows blobs to be read from an archive without scanning it, see Reader.
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.brendoncarroll.net/state/cadata"
+)
+
+const (
+	Version = 1
+
+	headerSize     = 8 + 1
+	blobHeaderSize = 4 + cadata.IDSize
+	indexEntrySize = cadata.IDSize + 8 + 4
+	footerSize     = 8 + 8
+	endMarker      = 0xFFFFFFFF
+)
+
+var (
+	magic      = [8]byte{'C', 'A', 'D', 'A', 'T', 'A', 'A', 'R'}
+	indexMagic = [8]byte{'C', 'A', 'D', 'A', 'T', 'A', 'I', 'X'}
+)
+
+var (
+	// ErrNoIndex is returned by NewReader when the archive does not have an index.
+	ErrNoIndex = errors.New("archive does not have an index")
+	// ErrNotSorted is returned by Writer.Add when blobs are not added in ascending order by ID.
+	ErrNotSorted = errors.New("blobs must be added in ascending order by ID")
+)
+
+// Export writes all the blobs in span from src to w as an archive with an index.
+// Archives are always in ascending order, so the direction of span is ignored.
+func Export(ctx context.Context, w io.Writer, src cadata.GetLister, span cadata.Span) error {
+	aw, err := NewWriter(w, true)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, src.MaxSize())
+	if err := cadata.ForEach(ctx, src, span.Asc(), func(id cadata.ID) error {
+		n, err := src.Get(ctx, id, buf)
+		if err != nil {
+			return err
+		}
+		return aw.Add(id, buf[:n])
+	}); err != nil {
+		return err
+	}
+	return aw.Close()
+}
+
+// ExportIDs writes the blobs identified by ids from src to w as an archive with an index.
+// ids does not need to be sorted or unique.
+func ExportIDs(ctx context.Context, w io.Writer, src cadata.Getter, ids []cadata.ID) error {
+	ids = sortIDs(ids)
+	aw, err := NewWriter(w, true)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, src.MaxSize())
+	for _, id := range ids {
+		n, err := src.Get(ctx, id, buf)
+		if err != nil {
+			return err
+		}
+		if err := aw.Add(id, buf[:n]); err != nil {
+			return err
+		}
+	}
+	return aw.Close()
+}
+
+// Import reads an archive from r and posts every blob in it to dst.
+// Each blob is checked against its ID using dst's hash function before it is posted.
+func Import(ctx context.Context, dst cadata.Poster, r io.Reader) error {
+	br := bufio.NewReader(r)
+	if err := readHeader(br); err != nil {
+		return err
+	}
+	var lenBuf [4]byte
+	var id cadata.ID
+	buf := make([]byte, dst.MaxSize())
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(br, lenBuf[:]); err != nil {
+			return noEOF(err)
+		}
+		length := binary.BigEndian.Uint32(lenBuf[:])
+		if length == endMarker {
+			return nil
+		}
+		if _, err := io.ReadFull(br, id[:]); err != nil {
+			return noEOF(err)
+		}
+		if int64(length) > int64(len(buf)) {
+			return fmt.Errorf("archive: blob %v is too large (%d > %d): %w", id, length, len(buf), cadata.ErrTooLarge)
+		}
+		data := buf[:length]
+		if _, err := io.ReadFull(br, data); err != nil {
+			return noEOF(err)
+		}
+		if err := cadata.Check(dst.Hash, id, data); err != nil {
+			return fmt.Errorf("archive: blob %v: %w", id, err)
+		}
+		if _, err := dst.Post(ctx, data); err != nil {
+			return err
+		}
+	}
+}
+
+// Writer writes an archive
+type Writer struct {
+	w         io.Writer
+	withIndex bool
+
+	offset int64
+	last   *cadata.ID
+	index  []indexEntry
+	closed bool
+}
+
+// NewWriter writes an archive header to w and returns a Writer for the rest of the archive.
+// If withIndex is true, an index will be written by Close.
+func NewWriter(w io.Writer, withIndex bool) (*Writer, error) {
+	var header [headerSize]byte
+	copy(header[:], magic[:])
+	header[8] = Version
+	if _, err := w.Write(header[:]); err != nil {
+		return nil, err
+	}
+	return &Writer{
+		w:         w,
+		withIndex: withIndex,
+		offset:    headerSize,
+	}, nil
+}
+
+// Add writes a blob to the archive.
+// Blobs must be added in ascending order by ID.
+func (w *Writer) Add(id cadata.ID, data []byte) error {
+	if w.closed {
+		return errors.New("archive: writer is closed")
+	}
+	if w.last != nil && w.last.Compare(id) >= 0 {
+		return ErrNotSorted
+	}
+	if int64(len(data)) >= endMarker {
+		return cadata.ErrTooLarge
+	}
+	var header [blobHeaderSize]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], id[:])
+	if _, err := w.w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(data); err != nil {
+		return err
+	}
+	if w.withIndex {
+		w.index = append(w.index, indexEntry{
+			ID:     id,
+			Offset: w.offset + blobHeaderSize,
+			Length: uint32(len(data)),
+		})
+	}
+	w.offset += blobHeaderSize + int64(len(data))
+	w.last = &id
+	return nil
+}
+
+// Close writes the end marker, and the index if the Writer has one.
+// Close does not close the underlying io.Writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	var end [4]byte
+	binary.BigEndian.PutUint32(end[:], endMarker)
+	if _, err := w.w.Write(end[:]); err != nil {
+		return err
+	}
+	w.offset += int64(len(end))
+	if !w.withIndex {
+		return nil
+	}
+	indexOffset := w.offset
+	var entBuf [indexEntrySize]byte
+	for _, ent := range w.index {
+		ent.marshal(entBuf[:])
+		if _, err := w.w.Write(entBuf[:]); err != nil {
+			return err
+		}
+	}
+	var footer [footerSize]byte
+	binary.BigEndian.PutUint64(footer[:8], uint64(indexOffset))
+	copy(footer[8:], indexMagic[:])
+	_, err := w.w.Write(footer[:])
+	return err
+}
+
+type indexEntry struct {
+	ID     cadata.ID
+	Offset int64
+	Length uint32
+}
+
+func (e indexEntry) marshal(out []byte) {
+	copy(out[:cadata.IDSize], e.ID[:])
+	binary.BigEndian.PutUint64(out[cadata.IDSize:], uint64(e.Offset))
+	binary.BigEndian.PutUint32(out[cadata.IDSize+8:], e.Length)
+}
+
+func (e *indexEntry) unmarshal(x []byte) {
+	e.ID = cadata.IDFromBytes(x[:cadata.IDSize])
+	e.Offset = int64(binary.BigEndian.Uint64(x[cadata.IDSize:]))
+	e.Length = binary.BigEndian.Uint32(x[cadata.IDSize+8:])
+}
+
+func readHeader(r io.Reader) error {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return fmt.Errorf("archive: reading header: %w", noEOF(err))
+	}
+	if !bytes.Equal(header[:8], magic[:]) {
+		return errors.New("archive: not an archive, bad magic number")
+	}
+	if header[8] != Version {
+		return fmt.Errorf("archive: unsupported version %d", header[8])
+	}
+	return nil
+}
+
+// noEOF converts io.EOF into io.ErrUnexpectedEOF.
+// Archives end with an end marker, so EOF before that means the archive is truncated.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,148 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.brendoncarroll.net/state/cadata"
+)
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	src := newStore()
+	ids := postN(t, src, 100)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, Export(ctx, buf, src, cadata.Span{}))
+	dst := newStore()
+	require.NoError(t, Import(ctx, dst, bytes.NewReader(buf.Bytes())))
+	requireSameContents(t, src, dst)
+
+	// subset by span
+	buf.Reset()
+	span := cadata.Span{}.WithLowerIncl(ids[10]).WithUpperExcl(ids[20])
+	require.NoError(t, Export(ctx, buf, src, span))
+	dst = newStore()
+	require.NoError(t, Import(ctx, dst, bytes.NewReader(buf.Bytes())))
+	require.Equal(t, 10, dst.Len())
+
+	// descending spans export the same archive
+	want := append([]byte{}, buf.Bytes()...)
+	buf.Reset()
+	require.NoError(t, Export(ctx, buf, src, span.Desc()))
+	require.Equal(t, want, buf.Bytes())
+}
+
+func TestImportBadData(t *testing.T) {
+	ctx := context.Background()
+	src := newStore()
+	postN(t, src, 10)
+	buf := &bytes.Buffer{}
+	require.NoError(t, Export(ctx, buf, src, cadata.Span{}))
+
+	data := buf.Bytes()
+	// flip a bit in the first blob's data
+	data[headerSize+blobHeaderSize] ^= 1
+	err := Import(ctx, newStore(), bytes.NewReader(data))
+	require.ErrorIs(t, err, cadata.ErrBadData)
+
+	data[headerSize+blobHeaderSize] ^= 1
+
+	// truncated archives are an error
+	err = Import(ctx, newStore(), bytes.NewReader(data[:len(data)/2]))
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestReader(t *testing.T) {
+	ctx := context.Background()
+	src := newStore()
+	ids := postN(t, src, 100)
+	buf := &bytes.Buffer{}
+	require.NoError(t, ExportIDs(ctx, buf, src, append(ids[50:], ids[:60]...)))
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()), cadata.DefaultHash)
+	require.NoError(t, err)
+	require.Equal(t, len(ids), r.Len())
+	for _, id := range ids {
+		expected, err := cadata.GetBytes(ctx, src, id)
+		require.NoError(t, err)
+		actual, err := cadata.GetBytes(ctx, r, id)
+		require.NoError(t, err)
+		require.Equal(t, expected, actual)
+	}
+	missing := cadata.DefaultHash([]byte("missing"))
+	_, err = cadata.GetBytes(ctx, r, missing)
+	require.True(t, cadata.IsNotFound(err))
+
+	var listed []cadata.ID
+	span := cadata.Span{}.WithLowerExcl(ids[10]).WithUpperIncl(ids[20])
+	require.NoError(t, cadata.ForEach(ctx, r, span, func(id cadata.ID) error {
+		listed = append(listed, id)
+		return nil
+	}))
+	require.Equal(t, ids[11:21], listed)
+
+	// nothing comes after the maximum ID.
+	var max cadata.ID
+	for i := range max {
+		max[i] = 0xff
+	}
+	n, err := r.List(ctx, cadata.Span{}.WithLowerExcl(max), make([]cadata.ID, 10))
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+}
+
+func TestNoIndex(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, err := NewWriter(buf, false)
+	require.NoError(t, err)
+	data := []byte("hello")
+	require.NoError(t, w.Add(cadata.DefaultHash(data), data))
+	require.ErrorIs(t, w.Add(cadata.ID{}, nil), ErrNotSorted)
+	require.NoError(t, w.Close())
+
+	_, err = NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()), cadata.DefaultHash)
+	require.ErrorIs(t, err, ErrNoIndex)
+	dst := newStore()
+	require.NoError(t, Import(context.Background(), dst, buf))
+	require.Equal(t, 1, dst.Len())
+}
+
+func newStore() *cadata.MemStore {
+	return cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize)
+}
+
+func postN(t testing.TB, s cadata.Store, n int) []cadata.ID {
+	ctx := context.Background()
+	var ids []cadata.ID
+	for i := 0; i < n; i++ {
+		id, err := s.Post(ctx, bytes.Repeat([]byte{byte(i)}, i*10))
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	return sortIDs(ids)
+}
+
+func requireSameContents(t testing.TB, a, b cadata.Store) {
+	ctx := context.Background()
+	var count int
+	require.NoError(t, cadata.ForEach(ctx, a, cadata.Span{}, func(id cadata.ID) error {
+		count++
+		expected, err := cadata.GetBytes(ctx, a, id)
+		require.NoError(t, err)
+		actual, err := cadata.GetBytes(ctx, b, id)
+		require.NoError(t, err)
+		require.Equal(t, expected, actual)
+		return nil
+	}))
+	var count2 int
+	require.NoError(t, cadata.ForEach(ctx, b, cadata.Span{}, func(cadata.ID) error {
+		count2++
+		return nil
+	}))
+	require.Equal(t, count, count2)
+}
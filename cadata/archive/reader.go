@@ -0,0 +1,178 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"go.brendoncarroll.net/state/cadata"
+)
+
+var _ interface {
+	cadata.GetLister
+	cadata.Exister
+} = &Reader{}
+
+// Reader provides random access to the blobs in an archive using its index.
+type Reader struct {
+	r       io.ReaderAt
+	hash    cadata.HashFunc
+	index   []indexEntry
+	maxSize int
+}
+
+// NewReader reads the index from an archive of the given size.
+// If the archive does not have an index, ErrNoIndex is returned.
+// hf is used to verify blobs as they are read.
+func NewReader(r io.ReaderAt, size int64, hf cadata.HashFunc) (*Reader, error) {
+	if err := readHeader(io.NewSectionReader(r, 0, headerSize)); err != nil {
+		return nil, err
+	}
+	if size < headerSize+4+footerSize {
+		return nil, ErrNoIndex
+	}
+	var footer [footerSize]byte
+	if _, err := r.ReadAt(footer[:], size-footerSize); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(footer[8:], indexMagic[:]) {
+		return nil, ErrNoIndex
+	}
+	indexOffset := int64(binary.BigEndian.Uint64(footer[:8]))
+	indexSize := size - footerSize - indexOffset
+	if indexOffset < headerSize || indexSize < 0 || indexSize%indexEntrySize != 0 {
+		return nil, errors.New("archive: corrupt index")
+	}
+	indexData := make([]byte, indexSize)
+	if _, err := r.ReadAt(indexData, indexOffset); err != nil {
+		return nil, err
+	}
+	index := make([]indexEntry, indexSize/indexEntrySize)
+	var maxSize int
+	for i := range index {
+		index[i].unmarshal(indexData[i*indexEntrySize:])
+		if i > 0 && index[i-1].ID.Compare(index[i].ID) >= 0 {
+			return nil, errors.New("archive: corrupt index, not sorted")
+		}
+		if index[i].Offset+int64(index[i].Length) > indexOffset {
+			return nil, errors.New("archive: corrupt index, blob out of bounds")
+		}
+		if int(index[i].Length) > maxSize {
+			maxSize = int(index[i].Length)
+		}
+	}
+	return &Reader{
+		r:       r,
+		hash:    hf,
+		index:   index,
+		maxSize: maxSize,
+	}, nil
+}
+
+// Get reads the blob identified by id from the archive, and checks it against id.
+func (r *Reader) Get(ctx context.Context, id cadata.ID, buf []byte) (int, error) {
+	ent, ok := r.find(id)
+	if !ok {
+		return 0, cadata.ErrNotFound{Key: id}
+	}
+	if len(buf) < int(ent.Length) {
+		return 0, io.ErrShortBuffer
+	}
+	data := buf[:ent.Length]
+	if _, err := r.r.ReadAt(data, ent.Offset); err != nil {
+		return 0, err
+	}
+	if err := cadata.Check(r.hash, id, data); err != nil {
+		return 0, fmt.Errorf("archive: blob %v: %w", id, err)
+	}
+	return len(data), nil
+}
+
+func (r *Reader) Exists(ctx context.Context, id cadata.ID) (bool, error) {
+	_, ok := r.find(id)
+	return ok, nil
+}
+
+func (r *Reader) List(ctx context.Context, span cadata.Span, ids []cadata.ID) (int, error) {
+	if span.IsDesc() {
+		return r.listDesc(span, ids), nil
+	}
+	i := sort.Search(len(r.index), func(i int) bool {
+		return span.Compare(r.index[i].ID, compareIDs) <= 0
+	})
+	var n int
+	for ; i < len(r.index) && n < len(ids); i++ {
+		id := r.index[i].ID
+		if span.Compare(id, compareIDs) < 0 {
+			break
+		}
+		ids[n] = id
+		n++
+	}
+	return n, nil
+}
+
+// listDesc walks the index backwards from the end of span.
+func (r *Reader) listDesc(span cadata.Span, ids []cadata.ID) int {
+	i := sort.Search(len(r.index), func(i int) bool {
+		return span.Compare(r.index[i].ID, compareIDs) < 0
+	}) - 1
+	var n int
+	for ; i >= 0 && n < len(ids); i-- {
+		id := r.index[i].ID
+		if span.Compare(id, compareIDs) > 0 {
+			break
+		}
+		ids[n] = id
+		n++
+	}
+	return n
+}
+
+// Len returns the number of blobs in the archive.
+func (r *Reader) Len() int {
+	return len(r.index)
+}
+
+func (r *Reader) Hash(x []byte) cadata.ID {
+	return r.hash(x)
+}
+
+// MaxSize returns the size of the largest blob in the archive.
+func (r *Reader) MaxSize() int {
+	return r.maxSize
+}
+
+func (r *Reader) find(id cadata.ID) (indexEntry, bool) {
+	i := sort.Search(len(r.index), func(i int) bool {
+		return r.index[i].ID.Compare(id) >= 0
+	})
+	if i < len(r.index) && r.index[i].ID == id {
+		return r.index[i], true
+	}
+	return indexEntry{}, false
+}
+
+func compareIDs(a, b cadata.ID) int {
+	return a.Compare(b)
+}
+
+func sortIDs(ids []cadata.ID) []cadata.ID {
+	ids = append([]cadata.ID{}, ids...)
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Compare(ids[j]) < 0
+	})
+	var n int
+	for i := range ids {
+		if i > 0 && ids[i] == ids[n-1] {
+			continue
+		}
+		ids[n] = ids[i]
+		n++
+	}
+	return ids[:n]
+}
@@ -0,0 +1,433 @@
+// Package blobfs presents the contents of a cadata store as a read only filesystem.
+//
+// Files are named by the base64 encoding of their ID, split into directories by prefix, in the same layout as fsstore.
+// The ID "ABCDEF..." is at the path "AB/CDEF...".
+package blobfs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"go.brendoncarroll.net/state/cadata"
+	"go.brendoncarroll.net/state/posixfs"
+)
+
+const (
+	// dirPrefixLen is the number of base64 characters used to name directories.
+	dirPrefixLen = 2
+	// encodedIDLen is the length of an ID encoded as base64.
+	encodedIDLen = (cadata.IDSize*8 + 5) / 6
+)
+
+var (
+	_ fs.FS      = &FS{}
+	_ fs.StatFS  = &FS{}
+	_ posixfs.FS = &FS{}
+)
+
+// FS is a read only filesystem containing the blobs in a cadata store.
+// It implements both io/fs.FS and posixfs.FS
+type FS struct {
+	s   cadata.GetLister
+	ctx context.Context
+}
+
+// New returns a filesystem containing the blobs in s.
+func New(s cadata.GetLister) *FS {
+	return &FS{s: s, ctx: context.Background()}
+}
+
+// Open implements fs.FS
+func (fsys *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return fsys.openDir("", "."), nil
+	}
+	if isDirName(name) {
+		if err := fsys.checkDir("open", name); err != nil {
+			return nil, err
+		}
+		return fsys.openDir(name, name), nil
+	}
+	return fsys.openFile("open", name)
+}
+
+// Stat implements fs.StatFS and posixfs.FS
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	name = cleanPath(name)
+	if name == "." {
+		return dirInfo("."), nil
+	}
+	if isDirName(name) {
+		if err := fsys.checkDir("stat", name); err != nil {
+			return nil, err
+		}
+		return dirInfo(name), nil
+	}
+	f, err := fsys.openFile("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return f.Stat()
+}
+
+// OpenFile implements posixfs.FS.
+// Only posixfs.O_RDONLY is allowed.
+func (fsys *FS) OpenFile(p string, flag int, perm fs.FileMode) (posixfs.File, error) {
+	const writeFlags = posixfs.O_WRONLY | posixfs.O_RDWR | posixfs.O_APPEND | posixfs.O_CREATE | posixfs.O_TRUNC
+	if flag&writeFlags != 0 {
+		return nil, posixfs.ErrReadOnly{Op: "open"}
+	}
+	p = cleanPath(p)
+	if p == "." {
+		return &posixDir{dir: fsys.openDir("", ".")}, nil
+	}
+	if isDirName(p) {
+		if err := fsys.checkDir("open", p); err != nil {
+			return nil, err
+		}
+		return &posixDir{dir: fsys.openDir(p, p)}, nil
+	}
+	return fsys.openFile("open", p)
+}
+
+func (fsys *FS) Mkdir(p string, perm fs.FileMode) error {
+	return posixfs.ErrReadOnly{Op: "mkdir"}
+}
+
+func (fsys *FS) Rmdir(p string) error {
+	return posixfs.ErrReadOnly{Op: "rmdir"}
+}
+
+func (fsys *FS) Remove(p string) error {
+	return posixfs.ErrReadOnly{Op: "remove"}
+}
+
+func (fsys *FS) Rename(oldPath, newPath string) error {
+	return posixfs.ErrReadOnly{Op: "rename"}
+}
+
+func (fsys *FS) Symlink(oldp, newp string) error {
+	return posixfs.ErrReadOnly{Op: "symlink"}
+}
+
+// checkDir returns fs.ErrNotExist if there are no IDs with the prefix name.
+func (fsys *FS) checkDir(op, name string) error {
+	span, err := cadata.PrefixSpan(name)
+	if err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	var ids [1]cadata.ID
+	n, err := fsys.s.List(fsys.ctx, span, ids[:])
+	if err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	if n == 0 {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return nil
+}
+
+func (fsys *FS) openDir(prefix, name string) *dir {
+	return &dir{fsys: fsys, prefix: prefix, name: name}
+}
+
+func (fsys *FS) openFile(op, name string) (*file, error) {
+	id, err := parsePath(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	data, err := cadata.GetBytes(fsys.ctx, fsys.s, id)
+	if err != nil {
+		if cadata.IsNotFound(err) {
+			err = fs.ErrNotExist
+		}
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return &file{
+		Reader: bytes.NewReader(data),
+		info:   fileInfo{name: path.Base(name), size: int64(len(data)), mode: 0o444},
+	}, nil
+}
+
+var (
+	_ fs.File      = &file{}
+	_ io.ReaderAt  = &file{}
+	_ posixfs.File = &file{}
+)
+
+// file is an open blob.  It implements fs.File and posixfs.File
+type file struct {
+	*bytes.Reader
+	info fileInfo
+}
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *file) Write([]byte) (int, error) {
+	return 0, posixfs.ErrReadOnly{Op: "write"}
+}
+
+func (f *file) Sync() error {
+	return nil
+}
+
+func (f *file) Close() error {
+	return nil
+}
+
+func (f *file) ReadDir(n int) ([]posixfs.DirEnt, error) {
+	return nil, &fs.PathError{Op: "readdir", Path: f.info.name, Err: errNotDir}
+}
+
+var _ fs.ReadDirFile = &dir{}
+
+// dir is a synthesized directory.
+// The root directory contains a directory for each prefix, and each prefix directory contains the IDs with that prefix.
+type dir struct {
+	fsys   *FS
+	prefix string
+	name   string
+
+	// next is the span of entries which have not been read yet.
+	next    cadata.Span
+	started bool
+	done    bool
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) {
+	return dirInfo(d.name), nil
+}
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errIsDir}
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+// ReadDir implements fs.ReadDirFile
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.started {
+		d.started = true
+		if d.prefix != "" {
+			span, err := cadata.PrefixSpan(d.prefix)
+			if err != nil {
+				return nil, err
+			}
+			d.next = span
+		}
+	}
+	var ents []fs.DirEntry
+	for !d.done && (n <= 0 || len(ents) < n) {
+		var ent fs.DirEntry
+		var err error
+		if d.prefix == "" {
+			ent, err = d.nextPrefix()
+		} else {
+			ent, err = d.nextID()
+		}
+		if err != nil {
+			return ents, err
+		}
+		if ent != nil {
+			ents = append(ents, ent)
+		}
+	}
+	if n > 0 && len(ents) == 0 {
+		return nil, io.EOF
+	}
+	return ents, nil
+}
+
+// nextPrefix returns the next prefix directory in the root.
+func (d *dir) nextPrefix() (fs.DirEntry, error) {
+	var ids [1]cadata.ID
+	n, err := d.fsys.s.List(d.fsys.ctx, d.next, ids[:])
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		d.done = true
+		return nil, nil
+	}
+	prefix := ids[0].String()[:dirPrefixLen]
+	span, err := cadata.PrefixSpan(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if end, ok := span.UpperBound(); ok {
+		d.next = d.next.WithLowerIncl(end)
+	} else {
+		d.done = true
+	}
+	return fs.FileInfoToDirEntry(dirInfo(prefix)), nil
+}
+
+// nextID returns the next file in a prefix directory.
+func (d *dir) nextID() (fs.DirEntry, error) {
+	var ids [1]cadata.ID
+	n, err := d.fsys.s.List(d.fsys.ctx, d.next, ids[:])
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		d.done = true
+		return nil, nil
+	}
+	d.next = d.next.WithLowerExcl(ids[0])
+	return &fileEntry{fsys: d.fsys, id: ids[0]}, nil
+}
+
+// fileEntry is a fs.DirEntry for a blob.
+// The blob is only read if Info is called.
+type fileEntry struct {
+	fsys *FS
+	id   cadata.ID
+}
+
+func (e *fileEntry) Name() string {
+	return e.id.String()[dirPrefixLen:]
+}
+
+func (e *fileEntry) IsDir() bool {
+	return false
+}
+
+func (e *fileEntry) Type() fs.FileMode {
+	return 0
+}
+
+func (e *fileEntry) Info() (fs.FileInfo, error) {
+	f, err := e.fsys.openFile("stat", pathForID(e.id))
+	if err != nil {
+		return nil, err
+	}
+	return f.Stat()
+}
+
+var _ posixfs.File = &posixDir{}
+
+// posixDir adapts dir to posixfs.File
+type posixDir struct {
+	dir *dir
+}
+
+func (d *posixDir) Stat() (fs.FileInfo, error) {
+	return d.dir.Stat()
+}
+
+func (d *posixDir) Read(buf []byte) (int, error) {
+	return d.dir.Read(buf)
+}
+
+func (d *posixDir) Write([]byte) (int, error) {
+	return 0, posixfs.ErrReadOnly{Op: "write"}
+}
+
+func (d *posixDir) Seek(int64, int) (int64, error) {
+	return 0, &fs.PathError{Op: "seek", Path: d.dir.name, Err: errIsDir}
+}
+
+func (d *posixDir) Sync() error {
+	return nil
+}
+
+func (d *posixDir) Close() error {
+	return d.dir.Close()
+}
+
+func (d *posixDir) ReadDir(n int) ([]posixfs.DirEnt, error) {
+	ents, err := d.dir.ReadDir(n)
+	ret := make([]posixfs.DirEnt, len(ents))
+	for i, ent := range ents {
+		mode := ent.Type()
+		if mode.IsDir() {
+			mode |= 0o555
+		} else {
+			mode |= 0o444
+		}
+		ret[i] = posixfs.DirEnt{Name: ent.Name(), Mode: mode}
+	}
+	return ret, err
+}
+
+type fileInfo struct {
+	name string
+	size int64
+	mode fs.FileMode
+}
+
+func dirInfo(name string) fileInfo {
+	return fileInfo{name: name, mode: fs.ModeDir | 0o555}
+}
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return fi.size }
+func (fi fileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi fileInfo) ModTime() time.Time { return time.Time{} }
+func (fi fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi fileInfo) Sys() interface{}   { return nil }
+
+var (
+	errIsDir  = errorString("is a directory")
+	errNotDir = errorString("not a directory")
+)
+
+type errorString string
+
+func (e errorString) Error() string {
+	return string(e)
+}
+
+func pathForID(id cadata.ID) string {
+	p := id.String()
+	return path.Join(p[:dirPrefixLen], p[dirPrefixLen:])
+}
+
+func parsePath(p string) (cadata.ID, error) {
+	parts := strings.SplitN(p, "/", 2)
+	if len(parts) != 2 || len(parts[0]) != dirPrefixLen || len(parts[0])+len(parts[1]) != encodedIDLen {
+		return cadata.ID{}, fs.ErrNotExist
+	}
+	var id cadata.ID
+	if err := id.UnmarshalBase64([]byte(parts[0] + parts[1])); err != nil {
+		return cadata.ID{}, err
+	}
+	// reject paths which are not the canonical encoding of the ID.
+	if pathForID(id) != p {
+		return cadata.ID{}, fs.ErrNotExist
+	}
+	return id, nil
+}
+
+func isDirName(name string) bool {
+	if len(name) != dirPrefixLen {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if strings.IndexByte(cadata.Base64Alphabet, name[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// cleanPath converts a posixfs path to an io/fs path.
+func cleanPath(p string) string {
+	p = path.Clean("/" + p)
+	if p == "/" {
+		return "."
+	}
+	return p[1:]
+}
@@ -0,0 +1,61 @@
+package blobfs
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+
+	"go.brendoncarroll.net/state/cadata"
+	"go.brendoncarroll.net/state/posixfs"
+)
+
+func TestFS(t *testing.T) {
+	s, ids := newTestStore(t, 300)
+	expected := make([]string, len(ids))
+	for i, id := range ids {
+		expected[i] = pathForID(id)
+	}
+	require.NoError(t, fstest.TestFS(New(s), expected...))
+}
+
+func TestPOSIX(t *testing.T) {
+	ctx := context.Background()
+	s, ids := newTestStore(t, 50)
+	var fsx posixfs.FS = New(s)
+
+	var walked []cadata.ID
+	require.NoError(t, posixfs.WalkLeaves(ctx, fsx, "", func(p string, _ posixfs.DirEnt) error {
+		id, err := parsePath(p)
+		require.NoError(t, err)
+		walked = append(walked, id)
+		return nil
+	}))
+	require.ElementsMatch(t, ids, walked)
+
+	data, err := posixfs.ReadFile(ctx, fsx, pathForID(ids[0]))
+	require.NoError(t, err)
+	expected, err := cadata.GetBytes(ctx, s, ids[0])
+	require.NoError(t, err)
+	require.Equal(t, expected, data)
+
+	_, err = fsx.OpenFile("test", posixfs.O_CREATE|posixfs.O_WRONLY, 0o644)
+	require.ErrorAs(t, err, &posixfs.ErrReadOnly{})
+	require.ErrorAs(t, fsx.Remove(pathForID(ids[0])), &posixfs.ErrReadOnly{})
+	_, err = fsx.Stat("zz/" + ids[0].String()[2:])
+	require.True(t, posixfs.IsErrNotExist(err))
+}
+
+func newTestStore(t testing.TB, n int) (*cadata.MemStore, []cadata.ID) {
+	ctx := context.Background()
+	s := cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize)
+	var ids []cadata.ID
+	for i := 0; i < n; i++ {
+		id, err := s.Post(ctx, []byte(fmt.Sprint("blob ", i)))
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	return s, ids
+}
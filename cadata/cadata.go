@@ -48,11 +48,17 @@ type Deleter interface {
 	Delete(ctx context.Context, id ID) error
 }
 
+// SpanDeleter defines the DeleteSpan method
+type SpanDeleter interface {
+	// DeleteSpan removes all the IDs contained by span from the store, and returns the number removed.
+	DeleteSpan(ctx context.Context, span Span) (int, error)
+}
+
 // Lister defines the List method
 type Lister interface {
 	// List reads IDs from the store, in asceding order into ids.
-	// All the ids will be >= gteq, if it is not nil.
-	// All the ids will be < lt if it is not nil.
+	// If span.IsDesc() the IDs are in descending order instead.
+	// All the ids will be contained by span.
 	List(ctx context.Context, span Span, ids []ID) (int, error)
 }
 
@@ -79,6 +85,11 @@ type PostExister interface {
 	Exister
 }
 
+type ListDeleter interface {
+	Lister
+	Deleter
+}
+
 type Set interface {
 	Adder
 	Deleter
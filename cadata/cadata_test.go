@@ -27,3 +27,28 @@ func TestBase64Marshal(t *testing.T) {
 		require.Equal(t, expected, actual)
 	}
 }
+
+func TestSpanBounds(t *testing.T) {
+	var maxID ID
+	for i := range maxID {
+		maxID[i] = 0xff
+	}
+	id := ID{1, 2, 3}
+
+	require.Equal(t, ID{}, BeginFromSpan(Span{}))
+	require.Equal(t, id, BeginFromSpan(Span{}.WithLowerIncl(id)))
+	require.Equal(t, id.Successor(), BeginFromSpan(Span{}.WithLowerExcl(id)))
+
+	_, ok := EndFromSpan(Span{})
+	require.False(t, ok)
+	end, ok := EndFromSpan(Span{}.WithUpperExcl(id))
+	require.True(t, ok)
+	require.Equal(t, id, end)
+	end, ok = EndFromSpan(Span{}.WithUpperIncl(id))
+	require.True(t, ok)
+	require.Equal(t, id.Successor(), end)
+	// there is no ID greater than maxID
+	_, ok = EndFromSpan(Span{}.WithUpperIncl(maxID))
+	require.False(t, ok)
+	require.Equal(t, ID{}, maxID.Successor())
+}
@@ -0,0 +1,230 @@
+package cadata
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+)
+
+// RefsFunc returns the IDs of the blobs referenced by data.
+type RefsFunc = func(data []byte) ([]ID, error)
+
+// CopyDAG copies root, and every blob reachable from it, from src to dst.
+// Blobs are posted to dst after all the blobs they reference.
+// That means if dst already has a blob, it must also have everything reachable from it,
+// so CopyDAG skips that blob and all of its references.
+func CopyDAG(ctx context.Context, dst PostExister, src Getter, root ID, refs RefsFunc) error {
+	c := dagCopier{
+		dst:     dst,
+		src:     src,
+		refs:    refs,
+		buf:     make([]byte, src.MaxSize()),
+		visited: make(map[ID]struct{}),
+	}
+	return c.copy(ctx, root)
+}
+
+type dagCopier struct {
+	dst     PostExister
+	src     Getter
+	refs    RefsFunc
+	buf     []byte
+	visited map[ID]struct{}
+}
+
+func (c *dagCopier) copy(ctx context.Context, id ID) error {
+	if _, yes := c.visited[id]; yes {
+		return nil
+	}
+	c.visited[id] = struct{}{}
+	exists, err := c.dst.Exists(ctx, id)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	n, err := c.src.Get(ctx, id, c.buf)
+	if err != nil {
+		return err
+	}
+	data := append([]byte{}, c.buf[:n]...)
+	children, err := c.refs(data)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := c.copy(ctx, child); err != nil {
+			return err
+		}
+	}
+	id2, err := c.dst.Post(ctx, data)
+	if err != nil {
+		return err
+	}
+	if id != id2 {
+		return errors.New("stores have different hash functions")
+	}
+	return nil
+}
+
+// WalkOrder is the order that WalkDAG visits blobs in.
+type WalkOrder int
+
+const (
+	// BreadthFirst visits every blob at one depth before any blob at the next depth.
+	BreadthFirst WalkOrder = iota
+	// DepthFirst visits each blob before the blobs it references, and each reference's
+	// blobs before the next reference.
+	// When Parallelism > 1, sibling references may be walked concurrently.
+	DepthFirst
+)
+
+type WalkParams struct {
+	Order WalkOrder
+	// Parallelism is the maximum number of blobs to retrieve at once.
+	// 0 is treated as 1.
+	Parallelism int
+}
+
+// WalkDAG calls fn with root, and every blob reachable from it, exactly once.
+// data is only valid until fn returns.
+// fn may be called concurrently if params.Parallelism > 1.
+func WalkDAG(ctx context.Context, src Getter, root ID, refs RefsFunc, params WalkParams, fn func(id ID, data []byte) error) error {
+	if params.Parallelism < 1 {
+		params.Parallelism = 1
+	}
+	w := &dagWalker{
+		src:  src,
+		refs: refs,
+		fn:   fn,
+		pool: sync.Pool{New: func() interface{} {
+			buf := make([]byte, src.MaxSize())
+			return &buf
+		}},
+		visited: make(map[ID]struct{}),
+	}
+	switch params.Order {
+	case BreadthFirst:
+		return w.breadthFirst(ctx, root, params.Parallelism)
+	case DepthFirst:
+		w.sem = semaphore.NewWeighted(int64(params.Parallelism - 1))
+		return w.depthFirst(ctx, root)
+	default:
+		return errors.New("cadata: unknown WalkOrder")
+	}
+}
+
+// DAGSize returns the total size of root and every blob reachable from it.
+// Blobs reachable by more than one path are only counted once.
+func DAGSize(ctx context.Context, src Getter, root ID, refs RefsFunc) (int64, error) {
+	var mu sync.Mutex
+	var total int64
+	err := WalkDAG(ctx, src, root, refs, WalkParams{Parallelism: 1}, func(_ ID, data []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		total += int64(len(data))
+		return nil
+	})
+	return total, err
+}
+
+type dagWalker struct {
+	src  Getter
+	refs RefsFunc
+	fn   func(ID, []byte) error
+	pool sync.Pool
+	sem  *semaphore.Weighted
+
+	mu      sync.Mutex
+	visited map[ID]struct{}
+}
+
+func (w *dagWalker) breadthFirst(ctx context.Context, root ID, parallelism int) error {
+	w.markVisited(root)
+	level := []ID{root}
+	for len(level) > 0 {
+		children := make([][]ID, len(level))
+		sem := semaphore.NewWeighted(int64(parallelism))
+		eg, ctx := errgroup.WithContext(ctx)
+		for i := range level {
+			i := i
+			if err := sem.Acquire(ctx, 1); err != nil {
+				eg.Wait()
+				return err
+			}
+			eg.Go(func() error {
+				defer sem.Release(1)
+				var err error
+				children[i], err = w.visit(ctx, level[i])
+				return err
+			})
+		}
+		if err := eg.Wait(); err != nil {
+			return err
+		}
+		var next []ID
+		for _, ids := range children {
+			for _, id := range ids {
+				if w.markVisited(id) {
+					next = append(next, id)
+				}
+			}
+		}
+		level = next
+	}
+	return nil
+}
+
+func (w *dagWalker) depthFirst(ctx context.Context, id ID) error {
+	if !w.markVisited(id) {
+		return nil
+	}
+	children, err := w.visit(ctx, id)
+	if err != nil {
+		return err
+	}
+	eg, ctx := errgroup.WithContext(ctx)
+	for _, child := range children {
+		child := child
+		if w.sem.TryAcquire(1) {
+			eg.Go(func() error {
+				defer w.sem.Release(1)
+				return w.depthFirst(ctx, child)
+			})
+		} else if err := w.depthFirst(ctx, child); err != nil {
+			eg.Wait()
+			return err
+		}
+	}
+	return eg.Wait()
+}
+
+// visit retrieves id, calls fn with it, and returns the IDs it references.
+func (w *dagWalker) visit(ctx context.Context, id ID) ([]ID, error) {
+	bufp := w.pool.Get().(*[]byte)
+	defer w.pool.Put(bufp)
+	n, err := w.src.Get(ctx, id, *bufp)
+	if err != nil {
+		return nil, err
+	}
+	data := (*bufp)[:n]
+	if err := w.fn(id, data); err != nil {
+		return nil, err
+	}
+	return w.refs(data)
+}
+
+// markVisited returns true if id had not been visited yet.
+func (w *dagWalker) markVisited(id ID) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, yes := w.visited[id]; yes {
+		return false
+	}
+	w.visited[id] = struct{}{}
+	return true
+}
@@ -0,0 +1,135 @@
+package cadata_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.brendoncarroll.net/state/cadata"
+)
+
+func TestCopyDAG(t *testing.T) {
+	ctx := context.Background()
+	src := cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize)
+	root, all := postTestDAG(t, src)
+
+	dst := cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize)
+	require.NoError(t, cadata.CopyDAG(ctx, dst, src, root, testRefs))
+	require.Equal(t, len(all), dst.Len())
+
+	// If dst has a node, its children are assumed to be present too.
+	dst = cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize)
+	child := firstRef(t, src, root)
+	require.NoError(t, cadata.Copy(ctx, dst, src, child))
+	require.NoError(t, cadata.CopyDAG(ctx, dst, src, root, testRefs))
+	exists, err := dst.Exists(ctx, firstRef(t, src, child))
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func TestWalkDAG(t *testing.T) {
+	ctx := context.Background()
+	src := cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize)
+	root, all := postTestDAG(t, src)
+	depths := map[cadata.ID]int{root: 0}
+	for _, params := range []cadata.WalkParams{
+		{Order: cadata.BreadthFirst},
+		{Order: cadata.BreadthFirst, Parallelism: 4},
+		{Order: cadata.DepthFirst},
+		{Order: cadata.DepthFirst, Parallelism: 4},
+	} {
+		params := params
+		t.Run(fmt.Sprintf("%v-%d", params.Order, params.Parallelism), func(t *testing.T) {
+			var mu sync.Mutex
+			var visited []cadata.ID
+			err := cadata.WalkDAG(ctx, src, root, testRefs, params, func(id cadata.ID, data []byte) error {
+				mu.Lock()
+				defer mu.Unlock()
+				refs, err := testRefs(data)
+				require.NoError(t, err)
+				for _, ref := range refs {
+					if _, exists := depths[ref]; !exists {
+						depths[ref] = depths[id] + 1
+					}
+				}
+				visited = append(visited, id)
+				return nil
+			})
+			require.NoError(t, err)
+			require.ElementsMatch(t, all, visited)
+			require.Equal(t, root, visited[0])
+			if params.Order == cadata.BreadthFirst {
+				for i := 1; i < len(visited); i++ {
+					require.LessOrEqual(t, depths[visited[i-1]], depths[visited[i]])
+				}
+			}
+		})
+	}
+	t.Run("Size", func(t *testing.T) {
+		var expected int64
+		for _, id := range all {
+			data, err := cadata.GetBytes(ctx, src, id)
+			require.NoError(t, err)
+			expected += int64(len(data))
+		}
+		actual, err := cadata.DAGSize(ctx, src, root, testRefs)
+		require.NoError(t, err)
+		require.Equal(t, expected, actual)
+	})
+}
+
+// postTestDAG posts a DAG of depth 4, where each node has 3 children,
+// and some of the leaves are shared between parents.
+func postTestDAG(t testing.TB, s cadata.Poster) (root cadata.ID, all []cadata.ID) {
+	ctx := context.Background()
+	seen := map[cadata.ID]struct{}{}
+	var post func(depth, i int) cadata.ID
+	post = func(depth, i int) cadata.ID {
+		data := []byte(fmt.Sprintf("%d-%d", depth, i))
+		if depth < 3 {
+			var refs []byte
+			for j := 0; j < 3; j++ {
+				id := post(depth+1, i*3+j)
+				refs = append(refs, id[:]...)
+			}
+			data = append(refs, data...)
+			data = append([]byte{byte(len(refs) / cadata.IDSize)}, data...)
+		} else {
+			// leaves are shared by 2 parents
+			data = []byte(fmt.Sprintf("leaf-%d", i/6))
+			data = append([]byte{0}, data...)
+		}
+		id, err := s.Post(ctx, data)
+		require.NoError(t, err)
+		if _, exists := seen[id]; !exists {
+			seen[id] = struct{}{}
+			all = append(all, id)
+		}
+		return id
+	}
+	root = post(0, 0)
+	return root, all
+}
+
+// testRefs parses blobs created by postTestDAG.
+func testRefs(data []byte) ([]cadata.ID, error) {
+	if len(data) < 1 || len(data) < 1+int(data[0])*cadata.IDSize {
+		return nil, fmt.Errorf("invalid test blob")
+	}
+	var ids []cadata.ID
+	for i := 0; i < int(data[0]); i++ {
+		ids = append(ids, cadata.IDFromBytes(data[1+i*cadata.IDSize:]))
+	}
+	return ids, nil
+}
+
+func firstRef(t testing.TB, s cadata.Getter, id cadata.ID) cadata.ID {
+	data, err := cadata.GetBytes(context.Background(), s, id)
+	require.NoError(t, err)
+	refs, err := testRefs(data)
+	require.NoError(t, err)
+	return refs[0]
+}
@@ -0,0 +1,101 @@
+package erasure
+
+import (
+	"errors"
+	"fmt"
+)
+
+// codec is a systematic Reed-Solomon code with k data shards and m parity shards.
+// The encoding matrix is the k x k identity matrix on top of an m x k Cauchy matrix,
+// so any k rows of it form an invertible matrix.
+type codec struct {
+	k, m   int
+	matrix [][]byte
+}
+
+func newCodec(k, m int) (*codec, error) {
+	if k < 1 || m < 0 {
+		return nil, fmt.Errorf("erasure: invalid number of shards k=%d m=%d", k, m)
+	}
+	if k+m > 256 {
+		return nil, fmt.Errorf("erasure: too many shards k+m=%d > 256", k+m)
+	}
+	matrix := make([][]byte, k+m)
+	for i := range matrix {
+		matrix[i] = make([]byte, k)
+		if i < k {
+			matrix[i][i] = 1
+			continue
+		}
+		for j := 0; j < k; j++ {
+			// x_i = i, y_j = j, x_i != y_j because i >= k > j
+			matrix[i][j] = gfInv(byte(i) ^ byte(j))
+		}
+	}
+	return &codec{k: k, m: m, matrix: matrix}, nil
+}
+
+// encode computes the parity shards from the data shards.
+// shards must have length k+m, and every shard must have the same length.
+func (c *codec) encode(shards [][]byte) {
+	for i := c.k; i < c.k+c.m; i++ {
+		out := shards[i]
+		for b := range out {
+			out[b] = 0
+		}
+		for j := 0; j < c.k; j++ {
+			gfMulAdd(out, c.matrix[i][j], shards[j])
+		}
+	}
+}
+
+// reconstruct fills in the missing shards, which are nil.
+// At least k shards must be present.
+func (c *codec) reconstruct(shards [][]byte) error {
+	var present []int
+	shardSize := -1
+	for i, shard := range shards {
+		if shard != nil {
+			present = append(present, i)
+			shardSize = len(shard)
+		}
+	}
+	if len(present) < c.k {
+		return ErrTooFewShards
+	}
+	if len(present) == len(shards) {
+		return nil
+	}
+	present = present[:c.k]
+	sub := make([][]byte, c.k)
+	for i, row := range present {
+		sub[i] = c.matrix[row]
+	}
+	inv, ok := gfInvert(sub)
+	if !ok {
+		return errors.New("erasure: encoding matrix is singular")
+	}
+	// recover missing data shards
+	for j := 0; j < c.k; j++ {
+		if shards[j] != nil {
+			continue
+		}
+		out := make([]byte, shardSize)
+		for r, idx := range present {
+			gfMulAdd(out, inv[j][r], shards[idx])
+		}
+		shards[j] = out
+	}
+	// recompute missing parity shards
+	for i := c.k; i < c.k+c.m; i++ {
+		if shards[i] != nil {
+			continue
+		}
+		out := make([]byte, shardSize)
+		for j := 0; j < c.k; j++ {
+			gfMulAdd(out, c.matrix[i][j], shards[j])
+		}
+		shards[i] = out
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+// Package erasure provides a cadata.Store which splits data into Reed-Solomon coded shards across several stores.
+package erasure
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"golang.org/x/sync/errgroup"
+
+	"go.brendoncarroll.net/state/cadata"
+	"go.brendoncarroll.net/state/kv"
+)
+
+// shardHeaderSize is the size of the header prepended to each shard.
+// The header holds the length of the original blob, and a checksum of the length and the shard.
+const shardHeaderSize = 4 + 4
+
+// ErrTooFewShards is returned when not enough shards are available to reconstruct a blob.
+var ErrTooFewShards = errors.New("erasure: too few shards to reconstruct data")
+
+type Params struct {
+	// Inner holds the shards. Shard i of every blob is stored in Inner[i].
+	// The first DataShards stores hold data, and the rest hold parity.
+	Inner []kv.Store[cadata.ID, []byte]
+	// DataShards is the number of shards needed to reconstruct a blob.
+	DataShards int
+	// Index holds the IDs of the blobs in the Store, and is used for Exists and List.
+	Index cadata.Set
+	// Hash is the hash function used to identify blobs.
+	Hash cadata.HashFunc
+	// MaxSize is the maximum size of a blob.
+	MaxSize int
+}
+
+var _ cadata.Store = &Store{}
+
+// Store is an erasure coded cadata.Store.
+// Each blob is split into DataShards data shards and len(Inner) - DataShards parity shards,
+// and can be read back as long as any DataShards of the shards are available.
+type Store struct {
+	p     Params
+	codec *codec
+}
+
+func New(params Params) (*Store, error) {
+	c, err := newCodec(params.DataShards, len(params.Inner)-params.DataShards)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{p: params, codec: c}, nil
+}
+
+func (s *Store) Post(ctx context.Context, data []byte) (cadata.ID, error) {
+	if len(data) > s.MaxSize() {
+		return cadata.ID{}, cadata.ErrTooLarge
+	}
+	id := s.p.Hash(data)
+	shards := s.split(data)
+	s.codec.encode(shards)
+	eg, ctx2 := errgroup.WithContext(ctx)
+	for i := range shards {
+		i := i
+		eg.Go(func() error {
+			return s.putShard(ctx2, id, i, len(data), shards[i])
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return cadata.ID{}, err
+	}
+	if err := s.p.Index.Add(ctx, id); err != nil {
+		return cadata.ID{}, err
+	}
+	return id, nil
+}
+
+// Get reads the data shards for id, and falls back to the parity shards if any of them are unavailable.
+// The reconstructed data is checked against id.
+func (s *Store) Get(ctx context.Context, id cadata.ID, buf []byte) (int, error) {
+	shards := make([][]byte, s.numShards())
+	size, err := s.getShards(ctx, id, shards, s.p.DataShards)
+	if err != nil {
+		return 0, err
+	}
+	if size > len(buf) {
+		return 0, io.ErrShortBuffer
+	}
+	if err := s.codec.reconstruct(shards); err != nil {
+		return 0, err
+	}
+	n := s.join(buf[:size], shards)
+	if err := cadata.Check(s.p.Hash, id, buf[:n]); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *Store) Exists(ctx context.Context, id cadata.ID) (bool, error) {
+	return s.p.Index.Exists(ctx, id)
+}
+
+func (s *Store) List(ctx context.Context, span cadata.Span, ids []cadata.ID) (int, error) {
+	return s.p.Index.List(ctx, span, ids)
+}
+
+func (s *Store) Delete(ctx context.Context, id cadata.ID) error {
+	if err := s.p.Index.Delete(ctx, id); err != nil {
+		return err
+	}
+	eg, ctx := errgroup.WithContext(ctx)
+	for i := range s.p.Inner {
+		i := i
+		eg.Go(func() error {
+			return s.p.Inner[i].Delete(ctx, s.shardID(id, i))
+		})
+	}
+	return eg.Wait()
+}
+
+func (s *Store) Hash(x []byte) cadata.ID {
+	return s.p.Hash(x)
+}
+
+func (s *Store) MaxSize() int {
+	return s.p.MaxSize
+}
+
+// Repair rewrites any of the shards for id which are missing or corrupt.
+// It returns the number of shards which were rewritten.
+func (s *Store) Repair(ctx context.Context, id cadata.ID) (int, error) {
+	shards := make([][]byte, s.numShards())
+	size, err := s.getShards(ctx, id, shards, len(shards))
+	if err != nil {
+		return 0, err
+	}
+	var missing []int
+	for i := range shards {
+		if shards[i] == nil {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) == 0 {
+		return 0, nil
+	}
+	if err := s.codec.reconstruct(shards); err != nil {
+		return 0, err
+	}
+	data := make([]byte, size)
+	s.join(data, shards)
+	if err := cadata.Check(s.p.Hash, id, data); err != nil {
+		return 0, err
+	}
+	for _, i := range missing {
+		if err := s.putShard(ctx, id, i, size, shards[i]); err != nil {
+			return 0, err
+		}
+	}
+	return len(missing), nil
+}
+
+// RepairAll calls Repair on every blob in the Store.
+// It returns the total number of shards which were rewritten.
+func (s *Store) RepairAll(ctx context.Context) (int, error) {
+	var total int
+	err := cadata.ForEach(ctx, s.p.Index, cadata.Span{}, func(id cadata.ID) error {
+		n, err := s.Repair(ctx, id)
+		total += n
+		return err
+	})
+	return total, err
+}
+
+func (s *Store) numShards() int {
+	return len(s.p.Inner)
+}
+
+// shardID derives the ID that shard i of the blob id is stored under.
+func (s *Store) shardID(id cadata.ID, i int) cadata.ID {
+	var x [cadata.IDSize + 1]byte
+	copy(x[:], id[:])
+	x[cadata.IDSize] = byte(i)
+	return s.p.Hash(x[:])
+}
+
+// split divides data into k equal sized data shards, padded with zeros, and allocates m parity shards.
+func (s *Store) split(data []byte) [][]byte {
+	k := s.p.DataShards
+	shardSize := (len(data) + k - 1) / k
+	shards := make([][]byte, s.numShards())
+	for i := range shards {
+		shards[i] = make([]byte, shardSize)
+		if i < k && i*shardSize < len(data) {
+			copy(shards[i], data[i*shardSize:])
+		}
+	}
+	return shards
+}
+
+// join copies the data shards into out, which must be the size of the original blob.
+func (s *Store) join(out []byte, shards [][]byte) int {
+	var n int
+	for i := 0; i < s.p.DataShards && n < len(out); i++ {
+		n += copy(out[n:], shards[i])
+	}
+	return n
+}
+
+func (s *Store) putShard(ctx context.Context, id cadata.ID, i, size int, shard []byte) error {
+	val := make([]byte, shardHeaderSize+len(shard))
+	binary.BigEndian.PutUint32(val[0:4], uint32(size))
+	copy(val[shardHeaderSize:], shard)
+	binary.BigEndian.PutUint32(val[4:8], shardChecksum(val))
+	return s.p.Inner[i].Put(ctx, s.shardID(id, i), val)
+}
+
+// getShards reads shards for id into shards, until want shards have been read.
+// The data shards are read first, then parity shards as needed.
+// Missing and corrupt shards are left nil.
+// getShards returns the size of the original blob.
+func (s *Store) getShards(ctx context.Context, id cadata.ID, shards [][]byte, want int) (int, error) {
+	sizes := make([]int, len(shards))
+	errs := make([]error, len(shards))
+	fetch := func(begin, end int) {
+		eg, ctx := errgroup.WithContext(ctx)
+		for i := begin; i < end; i++ {
+			i := i
+			eg.Go(func() error {
+				shards[i], sizes[i], errs[i] = s.getShard(ctx, id, i)
+				return nil
+			})
+		}
+		eg.Wait()
+	}
+	count := func() (n int) {
+		for i := range shards {
+			if shards[i] != nil {
+				n++
+			}
+		}
+		return n
+	}
+	fetch(0, want)
+	if have := count(); have < s.p.DataShards {
+		fetch(want, len(shards))
+	}
+	size := -1
+	var notFound int
+	var firstErr error
+	for i := range shards {
+		switch {
+		case shards[i] != nil:
+			if size >= 0 && sizes[i] != size {
+				return 0, fmt.Errorf("erasure: shards disagree on size of %v", id)
+			}
+			size = sizes[i]
+		case cadata.IsNotFound(errs[i]):
+			notFound++
+		case errs[i] != nil && firstErr == nil:
+			firstErr = errs[i]
+		}
+	}
+	if have := count(); have < s.p.DataShards {
+		if notFound == len(shards) {
+			return 0, cadata.ErrNotFound{Key: id}
+		}
+		if firstErr != nil {
+			return 0, fmt.Errorf("%w: %d of %d shards available: %v", ErrTooFewShards, have, len(shards), firstErr)
+		}
+		return 0, fmt.Errorf("%w: %d of %d shards available", ErrTooFewShards, have, len(shards))
+	}
+	return size, nil
+}
+
+// getShard returns shard i of id, and the size of the original blob.
+// If the shard is corrupt, it returns nil and no error.
+func (s *Store) getShard(ctx context.Context, id cadata.ID, i int) ([]byte, int, error) {
+	var val []byte
+	if err := s.p.Inner[i].Get(ctx, s.shardID(id, i), &val); err != nil {
+		if cadata.IsNotFound(err) {
+			err = cadata.ErrNotFound{Key: id}
+		}
+		return nil, 0, err
+	}
+	if len(val) < shardHeaderSize {
+		return nil, 0, nil
+	}
+	if shardChecksum(val) != binary.BigEndian.Uint32(val[4:8]) {
+		return nil, 0, nil
+	}
+	size := int(binary.BigEndian.Uint32(val[0:4]))
+	return append([]byte{}, val[shardHeaderSize:]...), size, nil
+}
+
+// shardChecksum returns the checksum of a stored shard, which covers the size in its header as well as the shard.
+// A shard with a damaged size is then treated as corrupt, like one with damaged data.
+func shardChecksum(val []byte) uint32 {
+	sum := crc32.ChecksumIEEE(val[0:4])
+	return crc32.Update(sum, crc32.IEEETable, val[shardHeaderSize:])
+}
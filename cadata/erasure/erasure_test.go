@@ -0,0 +1,144 @@
+package erasure
+
+import (
+	"context"
+	mrand "math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.brendoncarroll.net/state/cadata"
+	"go.brendoncarroll.net/state/cadata/storetest"
+	"go.brendoncarroll.net/state/kv"
+)
+
+func TestStore(t *testing.T) {
+	storetest.TestStore(t, func(t testing.TB) cadata.Store {
+		s, _ := newTestStore(t, 4, 2)
+		return s
+	})
+}
+
+func TestReconstruct(t *testing.T) {
+	ctx := context.Background()
+	const k, m = 4, 3
+	s, inner := newTestStore(t, k, m)
+	data := make([]byte, 10_000)
+	mrand.New(mrand.NewSource(0)).Read(data)
+	id, err := s.Post(ctx, data)
+	require.NoError(t, err)
+
+	// lose a data shard, a parity shard, and corrupt another data shard.
+	require.NoError(t, inner[0].Delete(ctx, s.shardID(id, 0)))
+	require.NoError(t, inner[k].Delete(ctx, s.shardID(id, k)))
+	corruptShard(t, inner[2], s.shardID(id, 2))
+
+	actual, err := cadata.GetBytes(ctx, s, id)
+	require.NoError(t, err)
+	require.Equal(t, data, actual)
+
+	n, err := s.Repair(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	n, err = s.Repair(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	// after repairing, any m shards can be lost again.
+	for i := k; i < k+m; i++ {
+		require.NoError(t, inner[i].Delete(ctx, s.shardID(id, i)))
+	}
+	actual, err = cadata.GetBytes(ctx, s, id)
+	require.NoError(t, err)
+	require.Equal(t, data, actual)
+
+	// losing more than m shards loses the data
+	require.NoError(t, inner[1].Delete(ctx, s.shardID(id, 1)))
+	_, err = cadata.GetBytes(ctx, s, id)
+	require.ErrorIs(t, err, ErrTooFewShards)
+}
+
+func TestCorruptHeader(t *testing.T) {
+	ctx := context.Background()
+	s, inner := newTestStore(t, 4, 2)
+	data := make([]byte, 10_000)
+	mrand.New(mrand.NewSource(0)).Read(data)
+	id, err := s.Post(ctx, data)
+	require.NoError(t, err)
+
+	// a damaged size is treated as a missing shard, rather than failing the Get.
+	val, err := kv.Get[cadata.ID, []byte](ctx, inner[1], s.shardID(id, 1))
+	require.NoError(t, err)
+	val = append([]byte{}, val...)
+	val[3] ^= 1
+	require.NoError(t, inner[1].Put(ctx, s.shardID(id, 1), val))
+
+	actual, err := cadata.GetBytes(ctx, s, id)
+	require.NoError(t, err)
+	require.Equal(t, data, actual)
+}
+
+func TestCodec(t *testing.T) {
+	rng := mrand.New(mrand.NewSource(0))
+	for _, km := range [][2]int{{1, 1}, {2, 1}, {4, 2}, {10, 4}, {3, 0}} {
+		k, m := km[0], km[1]
+		c, err := newCodec(k, m)
+		require.NoError(t, err)
+		shards := make([][]byte, k+m)
+		for i := range shards {
+			shards[i] = make([]byte, 64)
+			if i < k {
+				rng.Read(shards[i])
+			}
+		}
+		c.encode(shards)
+		// try removing every combination of m shards, using a bitmask
+		for mask := 0; mask < 1<<(k+m); mask++ {
+			if popcount(mask) != m {
+				continue
+			}
+			damaged := make([][]byte, k+m)
+			for i := range damaged {
+				if mask&(1<<i) == 0 {
+					damaged[i] = append([]byte{}, shards[i]...)
+				}
+			}
+			require.NoError(t, c.reconstruct(damaged))
+			require.Equal(t, shards, damaged)
+		}
+	}
+}
+
+func newTestStore(t testing.TB, k, m int) (*Store, []kv.Store[cadata.ID, []byte]) {
+	inner := make([]kv.Store[cadata.ID, []byte], k+m)
+	for i := range inner {
+		inner[i] = kv.NewMemStore[cadata.ID, []byte](func(a, b cadata.ID) int {
+			return a.Compare(b)
+		})
+	}
+	s, err := New(Params{
+		Inner:      inner,
+		DataShards: k,
+		Index:      cadata.NewMemSet(),
+		Hash:       cadata.DefaultHash,
+		MaxSize:    cadata.DefaultMaxSize,
+	})
+	require.NoError(t, err)
+	return s, inner
+}
+
+func corruptShard(t testing.TB, s kv.Store[cadata.ID, []byte], id cadata.ID) {
+	ctx := context.Background()
+	val, err := kv.Get[cadata.ID, []byte](ctx, s, id)
+	require.NoError(t, err)
+	val = append([]byte{}, val...)
+	val[len(val)-1] ^= 1
+	require.NoError(t, s.Put(ctx, id, val))
+}
+
+func popcount(x int) (n int) {
+	for ; x > 0; x >>= 1 {
+		n += x & 1
+	}
+	return n
+}
@@ -0,0 +1,92 @@
+package erasure
+
+// Arithmetic in GF(2^8) using the polynomial x^8 + x^4 + x^3 + x^2 + 1 (0x11d).
+// Addition and subtraction are both XOR.
+
+var (
+	gfExp [510]byte
+	gfLog [256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for i := 255; i < len(gfExp); i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfInv(a byte) byte {
+	if a == 0 {
+		panic("erasure: inverse of 0")
+	}
+	return gfExp[255-int(gfLog[a])]
+}
+
+// gfMulAdd sets dst[i] ^= c * src[i] for every i.
+func gfMulAdd(dst []byte, c byte, src []byte) {
+	if c == 0 {
+		return
+	}
+	logc := int(gfLog[c])
+	for i, x := range src {
+		if x != 0 {
+			dst[i] ^= gfExp[logc+int(gfLog[x])]
+		}
+	}
+}
+
+// gfInvert returns the inverse of the square matrix m, or false if it is singular.
+func gfInvert(m [][]byte) ([][]byte, bool) {
+	n := len(m)
+	// augment m with the identity matrix, and reduce to [I | m^-1]
+	work := make([][]byte, n)
+	for i := range work {
+		work[i] = make([]byte, 2*n)
+		copy(work[i], m[i])
+		work[i][n+i] = 1
+	}
+	for col := 0; col < n; col++ {
+		pivot := -1
+		for row := col; row < n; row++ {
+			if work[row][col] != 0 {
+				pivot = row
+				break
+			}
+		}
+		if pivot < 0 {
+			return nil, false
+		}
+		work[col], work[pivot] = work[pivot], work[col]
+		if c := work[col][col]; c != 1 {
+			inv := gfInv(c)
+			for i := range work[col] {
+				work[col][i] = gfMul(work[col][i], inv)
+			}
+		}
+		for row := 0; row < n; row++ {
+			if row != col && work[row][col] != 0 {
+				gfMulAdd(work[row], work[row][col], work[col])
+			}
+		}
+	}
+	ret := make([][]byte, n)
+	for i := range ret {
+		ret[i] = work[i][n:]
+	}
+	return ret, true
+}
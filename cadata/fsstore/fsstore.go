@@ -56,25 +56,61 @@ func (s FSStore) Get(ctx context.Context, id cadata.ID, buf []byte) (int, error)
 	p := pathForID(id)
 	f, err := s.fs.OpenFile(p, posixfs.O_RDONLY, 0)
 	if err != nil {
+		if posixfs.IsErrNotExist(err) {
+			err = cadata.ErrNotFound{Key: id}
+		}
 		return 0, err
 	}
 	defer f.Close()
-	var n int
-	for {
-		n2, err := f.Read(buf)
-		n += n2
-		if err != nil {
-			if err == io.EOF {
-				err = nil
-			}
-			return n, err
-		}
+	finfo, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if finfo.Size() > int64(len(buf)) {
+		return 0, io.ErrShortBuffer
 	}
+	return io.ReadFull(f, buf[:finfo.Size()])
 }
 
 func (s FSStore) Exists(ctx context.Context, id cadata.ID) (bool, error) {
+	return exists(s.fs, id)
+}
+
+func (s FSStore) Delete(ctx context.Context, id cadata.ID) error {
 	p := pathForID(id)
-	finfo, err := s.fs.Stat(p)
+	return posixfs.DeleteFile(ctx, s.fs, p)
+}
+
+func (s FSStore) List(ctx context.Context, span cadata.Span, ids []cadata.ID) (int, error) {
+	return list(ctx, s.fs, span, ids)
+}
+
+// DeleteSpan removes the IDs in span.
+// Directories entirely contained by span are removed as a whole, rather than deleting each ID.
+func (s FSStore) DeleteSpan(ctx context.Context, span cadata.Span) (int, error) {
+	return deleteSpan(ctx, s.fs, span)
+}
+
+func (s FSStore) MaxSize() int {
+	return s.maxSize
+}
+
+func (s FSStore) Hash(x []byte) cadata.ID {
+	return s.hashFunc(x)
+}
+
+func (s FSStore) ensureDirForPath(p string) error {
+	return ensureDirForPath(s.fs, p)
+}
+
+func ensureDirForPath(fsx posixfs.FS, p string) error {
+	dirPath := path.Dir(p)
+	return posixfs.MkdirAll(fsx, dirPath, 0o755)
+}
+
+func exists(fsx posixfs.FS, id cadata.ID) (bool, error) {
+	p := pathForID(id)
+	finfo, err := fsx.Stat(p)
 	if posixfs.IsErrNotExist(err) {
 		return false, nil
 	}
@@ -87,30 +123,11 @@ func (s FSStore) Exists(ctx context.Context, id cadata.ID) (bool, error) {
 	return true, nil
 }
 
-func (s FSStore) Delete(ctx context.Context, id cadata.ID) error {
-	p := pathForID(id)
-	return posixfs.DeleteFile(ctx, s.fs, p)
-}
-
-func (s FSStore) List(ctx context.Context, span cadata.Span, ids []cadata.ID) (int, error) {
-	span2 := state.Span[string]{}
-	if lower, ok := span.LowerBound(); ok {
-		if span.IncludesLower() {
-			span2 = span2.WithLowerIncl(pathForID(lower))
-		} else {
-			span2 = span2.WithLowerExcl(pathForID(lower))
-		}
-	}
-	if upper, ok := span.UpperBound(); ok {
-		if span.IncludesUpper() {
-			span2 = span2.WithUpperIncl(pathForID(upper))
-		} else {
-			span2 = span2.WithUpperExcl(pathForID(upper))
-		}
-	}
+// list reads the IDs in span from a filesystem laid out by pathForID into ids.
+func list(ctx context.Context, fsx posixfs.FS, span cadata.Span, ids []cadata.ID) (int, error) {
 	var n int
 	stopIter := errors.New("stopIter")
-	err := posixfs.WalkLeavesSpan(ctx, s.fs, "", span2, func(p string, _ posixfs.DirEnt) error {
+	err := posixfs.WalkLeavesSpan(ctx, fsx, "", pathSpan(span), func(p string, _ posixfs.DirEnt) error {
 		if strings.HasPrefix(p, "tmp/") {
 			return nil
 		}
@@ -131,17 +148,100 @@ func (s FSStore) List(ctx context.Context, span cadata.Span, ids []cadata.ID) (i
 	return n, err
 }
 
-func (s FSStore) MaxSize() int {
-	return s.maxSize
+// deleteSpan removes the IDs in span from a filesystem laid out by pathForID.
+// Directories which are entirely contained by span are moved into tmp/ with a single rename, and then cleared.
+// Other directories are walked, and the files in span are removed one at a time.
+func deleteSpan(ctx context.Context, fsx posixfs.FS, span cadata.Span) (int, error) {
+	span2 := pathSpan(span)
+	dirEnts, err := posixfs.ReadDir(fsx, "")
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	for _, dirEnt := range dirEnts {
+		if !dirEnt.Mode.IsDir() || dirEnt.Name == "tmp" {
+			continue
+		}
+		// every path in the directory is >= begin and < end, since '0' is the byte after '/'.
+		begin, end := dirEnt.Name+"/", dirEnt.Name+"0"
+		if span2.Compare(begin, strings.Compare) < 0 || span2.Compare(end, strings.Compare) > 0 {
+			continue
+		}
+		var n int
+		if span2.Contains(begin, strings.Compare) && containsUpTo(span2, end) {
+			n, err = removeDir(ctx, fsx, dirEnt.Name)
+		} else {
+			err = posixfs.WalkLeavesSpan(ctx, fsx, dirEnt.Name, span2, func(p string, _ posixfs.DirEnt) error {
+				if err := fsx.Remove(p); posixfs.IsErrNotExist(err) {
+					return nil
+				} else if err != nil {
+					return err
+				}
+				n++
+				return nil
+			})
+		}
+		count += n
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
 }
 
-func (s FSStore) Hash(x []byte) cadata.ID {
-	return s.hashFunc(x)
+// containsUpTo returns true if span contains every string less than end.
+func containsUpTo(span state.Span[string], end string) bool {
+	upper, ok := span.UpperBound()
+	return !ok || upper >= end
 }
 
-func (s FSStore) ensureDirForPath(p string) error {
-	dirPath := path.Dir(p)
-	return posixfs.MkdirAll(s.fs, dirPath, 0o755)
+// removeDir moves the directory p into tmp/, so it is no longer visible, and then removes it.
+// It returns the number of files which were in the directory.
+func removeDir(ctx context.Context, fsx posixfs.FS, p string) (int, error) {
+	randBytes := [16]byte{}
+	if _, err := rand.Read(randBytes[:]); err != nil {
+		return 0, err
+	}
+	tmp := path.Join("tmp", fmt.Sprintf("%s.%x", p, randBytes))
+	if err := ensureDirForPath(fsx, tmp); err != nil {
+		return 0, err
+	}
+	if err := fsx.Rename(p, tmp); err != nil {
+		return 0, err
+	}
+	dirEnts, err := posixfs.ReadDir(fsx, tmp)
+	if err != nil {
+		return 0, err
+	}
+	for _, dirEnt := range dirEnts {
+		if err := fsx.Remove(path.Join(tmp, dirEnt.Name)); err != nil {
+			return 0, err
+		}
+	}
+	return len(dirEnts), fsx.Rmdir(tmp)
+}
+
+// pathSpan converts a span of IDs to the span of paths for them.
+func pathSpan(span cadata.Span) state.Span[string] {
+	span2 := state.Span[string]{}
+	if lower, ok := span.LowerBound(); ok {
+		if span.IncludesLower() {
+			span2 = span2.WithLowerIncl(pathForID(lower))
+		} else {
+			span2 = span2.WithLowerExcl(pathForID(lower))
+		}
+	}
+	if upper, ok := span.UpperBound(); ok {
+		if span.IncludesUpper() {
+			span2 = span2.WithUpperIncl(pathForID(upper))
+		} else {
+			span2 = span2.WithUpperExcl(pathForID(upper))
+		}
+	}
+	if span.IsDesc() {
+		span2 = span2.Desc()
+	}
+	return span2
 }
 
 var enc = base64.NewEncoding(cadata.Base64Alphabet).WithPadding(base64.NoPadding)
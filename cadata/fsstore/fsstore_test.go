@@ -2,10 +2,11 @@ package fsstore
 
 import (
 	"context"
-	mrand "math/rand"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
 	"go.brendoncarroll.net/state/cadata"
 	"go.brendoncarroll.net/state/cadata/storetest"
 	"go.brendoncarroll.net/state/posixfs"
@@ -19,21 +20,35 @@ func TestFSStore(t *testing.T) {
 }
 
 func BenchmarkStore(b *testing.B) {
-	ctx := context.Background()
-	rng := mrand.New(mrand.NewSource(0))
-	b.Run("Post", func(b *testing.B) {
-		b.ReportAllocs()
-		b.StopTimer()
-		fsx := posixfs.NewTestFS(b)
-		s := New(fsx, cadata.DefaultHash, cadata.DefaultMaxSize)
-		var buf [1 << 16]byte
-		for i := 0; i < b.N; i++ {
-			b.StopTimer()
-			rng.Read(buf[:])
-			b.StartTimer()
-			_, err := s.Post(ctx, buf[:])
-			require.NoError(b, err)
-			b.SetBytes(int64(len(buf)))
-		}
+	storetest.BenchmarkStore(b, func(t testing.TB) cadata.Store {
+		fsx := posixfs.NewTestFS(t)
+		return New(fsx, cadata.DefaultHash, cadata.DefaultMaxSize)
+	})
+}
+
+func TestSet(t *testing.T) {
+	storetest.TestSet(t, func(t testing.TB) cadata.Set {
+		return NewSet(posixfs.NewTestFS(t))
 	})
 }
+
+func TestDeleteSpanRemovesDirs(t *testing.T) {
+	ctx := context.Background()
+	fsx := posixfs.NewTestFS(t)
+	s := New(fsx, cadata.DefaultHash, cadata.DefaultMaxSize)
+	for i := 0; i < 100; i++ {
+		_, err := s.Post(ctx, []byte(strconv.Itoa(i)))
+		require.NoError(t, err)
+	}
+	n, err := s.DeleteSpan(ctx, cadata.Span{})
+	require.NoError(t, err)
+	require.Equal(t, 100, n)
+	dirEnts, err := posixfs.ReadDir(fsx, "")
+	require.NoError(t, err)
+	for _, dirEnt := range dirEnts {
+		require.Equal(t, "tmp", dirEnt.Name)
+	}
+	tmpEnts, err := posixfs.ReadDir(fsx, "tmp")
+	require.NoError(t, err)
+	require.Empty(t, tmpEnts)
+}
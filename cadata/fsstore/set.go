@@ -0,0 +1,48 @@
+package fsstore
+
+import (
+	"context"
+
+	"go.brendoncarroll.net/state/cadata"
+	"go.brendoncarroll.net/state/posixfs"
+)
+
+var _ cadata.Set = Set{}
+
+// Set is a cadata.Set which stores each ID as an empty file.
+// It uses the same layout as FSStore.
+type Set struct {
+	fs posixfs.FS
+}
+
+func NewSet(x posixfs.FS) Set {
+	return Set{fs: x}
+}
+
+func (s Set) Add(ctx context.Context, id cadata.ID) error {
+	p := pathForID(id)
+	if err := ensureDirForPath(s.fs, p); err != nil {
+		return err
+	}
+	f, err := s.fs.OpenFile(p, posixfs.O_WRONLY|posixfs.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (s Set) Delete(ctx context.Context, id cadata.ID) error {
+	return posixfs.DeleteFile(ctx, s.fs, pathForID(id))
+}
+
+func (s Set) Exists(ctx context.Context, id cadata.ID) (bool, error) {
+	return exists(s.fs, id)
+}
+
+func (s Set) List(ctx context.Context, span cadata.Span, ids []cadata.ID) (int, error) {
+	return list(ctx, s.fs, span, ids)
+}
+
+func (s Set) DeleteSpan(ctx context.Context, span cadata.Span) (int, error) {
+	return deleteSpan(ctx, s.fs, span)
+}
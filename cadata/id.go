@@ -125,7 +125,7 @@ func BeginFromSpan(x Span) ID {
 	return lower.Successor()
 }
 
-// EndFromSpan returns the cadata.ID which is greater than ever ID in the span, and true.
+// EndFromSpan returns the cadata.ID which is greater than every ID in the span, and true.
 // Or it returns the zero ID and false if no such ID exists.
 func EndFromSpan(x Span) (ID, bool) {
 	upper, ok := x.UpperBound()
@@ -136,5 +136,5 @@ func EndFromSpan(x Span) (ID, bool) {
 		return upper, true
 	}
 	suc := upper.Successor()
-	return suc, suc.IsZero()
+	return suc, !suc.IsZero()
 }
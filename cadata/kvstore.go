@@ -0,0 +1,84 @@
+package cadata
+
+import (
+	"context"
+	"io"
+
+	"go.brendoncarroll.net/state/kv"
+)
+
+var _ Store = KVStore{}
+
+// KVStore is a Store which keeps data in a kv.Store.
+// It handles hashing, enforcing the maximum size, and mapping errors,
+// so that any kv backend can be used as a content-addressed store.
+type KVStore struct {
+	kv      kv.Store[ID, []byte]
+	hash    HashFunc
+	maxSize int
+}
+
+// NewKVStore returns a Store which keeps data in x.
+func NewKVStore(x kv.Store[ID, []byte], hf HashFunc, maxSize int) KVStore {
+	return KVStore{
+		kv:      x,
+		hash:    hf,
+		maxSize: maxSize,
+	}
+}
+
+// NewKVStoreTx returns a Store which keeps data in x.
+// Each operation on the Store is performed in its own transaction.
+func NewKVStoreTx(x kv.StoreTx[ID, []byte], hf HashFunc, maxSize int) KVStore {
+	return NewKVStore(kv.FromStoreTx(x), hf, maxSize)
+}
+
+func (s KVStore) Post(ctx context.Context, data []byte) (ID, error) {
+	if len(data) > s.MaxSize() {
+		return ID{}, ErrTooLarge
+	}
+	data = append([]byte{}, data...)
+	id := s.hash(data)
+	if err := s.kv.Put(ctx, id, data); err != nil {
+		return ID{}, err
+	}
+	return id, nil
+}
+
+func (s KVStore) Get(ctx context.Context, id ID, buf []byte) (int, error) {
+	var data []byte
+	if err := s.kv.Get(ctx, id, &data); err != nil {
+		if IsNotFound(err) {
+			err = ErrNotFound{Key: id}
+		}
+		return 0, err
+	}
+	if len(buf) < len(data) {
+		return 0, io.ErrShortBuffer
+	}
+	return copy(buf, data), nil
+}
+
+func (s KVStore) List(ctx context.Context, span Span, ids []ID) (int, error) {
+	return s.kv.List(ctx, span, ids)
+}
+
+func (s KVStore) Delete(ctx context.Context, id ID) error {
+	return s.kv.Delete(ctx, id)
+}
+
+func (s KVStore) DeleteSpan(ctx context.Context, span Span) (int, error) {
+	return kv.DeleteSpan[ID](ctx, s.kv, span)
+}
+
+func (s KVStore) Exists(ctx context.Context, id ID) (bool, error) {
+	return s.kv.Exists(ctx, id)
+}
+
+func (s KVStore) Hash(x []byte) ID {
+	return s.hash(x)
+}
+
+func (s KVStore) MaxSize() int {
+	return s.maxSize
+}
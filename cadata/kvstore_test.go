@@ -0,0 +1,18 @@
+package cadata_test
+
+import (
+	"testing"
+
+	"go.brendoncarroll.net/state/cadata"
+	"go.brendoncarroll.net/state/cadata/storetest"
+	"go.brendoncarroll.net/state/kv"
+)
+
+func TestKVStoreTx(t *testing.T) {
+	storetest.TestStore(t, func(t testing.TB) cadata.Store {
+		x := kv.NewMemStore[cadata.ID, []byte](func(a, b cadata.ID) int {
+			return a.Compare(b)
+		})
+		return cadata.NewKVStoreTx(x, cadata.DefaultHash, cadata.DefaultMaxSize)
+	})
+}
@@ -1,76 +1,88 @@
 package cadata
 
 import (
-	"bytes"
 	"context"
-	"io"
 
 	"go.brendoncarroll.net/state/kv"
 )
 
-var _ Store = &MemStore{}
+var (
+	_ Store   = &MemStore{}
+	_ Watcher = &MemStore{}
+)
 
+// MemStore is a Store which keeps data in memory.
 type MemStore struct {
-	hash    HashFunc
-	maxSize int
-	s       *kv.MemStore[ID, []byte]
+	KVStore
+	s   *kv.MemStore[ID, []byte]
+	hub watchHub
 }
 
 func NewMem(hf HashFunc, maxSize int) *MemStore {
+	s := kv.NewMemStore[ID, []byte](func(a, b ID) int {
+		return a.Compare(b)
+	})
 	return &MemStore{
-		maxSize: maxSize,
-		hash:    hf,
-		s: kv.NewMemStore[ID, []byte](func(a, b ID) int {
-			return bytes.Compare(a[:], b[:])
-		}),
+		KVStore: NewKVStore(s, hf, maxSize),
+		s:       s,
+		hub:     newWatchHub(DefaultWatchBufferSize),
 	}
 }
 
 func (s *MemStore) Post(ctx context.Context, data []byte) (ID, error) {
-	if len(data) > s.MaxSize() {
-		return ID{}, ErrTooLarge
-	}
-	data = append([]byte{}, data...)
-	id := s.hash(data)
-	if err := s.s.Put(ctx, id, data); err != nil {
+	id, err := s.KVStore.Post(ctx, data)
+	if err != nil {
 		return ID{}, err
 	}
+	s.hub.publish(Event{Op: OpPost, ID: id})
 	return id, nil
 }
 
-func (s *MemStore) Get(ctx context.Context, id ID, buf []byte) (int, error) {
-	var data []byte
-	if err := s.s.Get(ctx, id, &data); err != nil {
-		return 0, err
-	}
-	if len(buf) < len(data) {
-		return 0, io.ErrShortBuffer
+func (s *MemStore) Delete(ctx context.Context, id ID) error {
+	if err := s.KVStore.Delete(ctx, id); err != nil {
+		return err
 	}
-	return copy(buf, data), nil
-}
-
-func (s *MemStore) List(ctx context.Context, span Span, ids []ID) (n int, err error) {
-	return s.s.List(ctx, span, ids)
+	s.hub.publish(Event{Op: OpDelete, ID: id})
+	return nil
 }
 
-func (s *MemStore) Delete(ctx context.Context, id ID) error {
-	return s.s.Delete(ctx, id)
+// DeleteSpan removes the IDs in span in a single transaction, then publishes a delete event for each of them.
+func (s *MemStore) DeleteSpan(ctx context.Context, span Span) (int, error) {
+	var deleted []ID
+	err := s.s.Modify(ctx, func(tx kv.Store[ID, []byte]) error {
+		deleted = deleted[:0]
+		_, err := kv.DeleteSpanBasic[ID](ctx, recordDeletes{tx, &deleted}, span)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range deleted {
+		s.hub.publish(Event{Op: OpDelete, ID: id})
+	}
+	return len(deleted), nil
 }
 
-func (s *MemStore) Exists(ctx context.Context, id ID) (bool, error) {
-	return s.s.Exists(ctx, id)
+func (s *MemStore) Watch(ctx context.Context, span Span, fn func(Event) error) error {
+	return s.hub.watch(ctx, s, span, fn)
 }
 
 func (s *MemStore) Len() (count int) {
 	return s.s.Len()
 }
 
-func (s *MemStore) Hash(x []byte) ID {
-	return s.hash(x)
+// recordDeletes appends the IDs it deletes to a slice.
+type recordDeletes struct {
+	kv.Store[ID, []byte]
+	ids *[]ID
 }
 
-func (s *MemStore) MaxSize() int {
-	return s.maxSize
+func (r recordDeletes) Delete(ctx context.Context, id ID) error {
+	if err := r.Store.Delete(ctx, id); err != nil {
+		return err
+	}
+	*r.ids = append(*r.ids, id)
+	return nil
 }
 
 var _ Store = Void{}
@@ -88,6 +100,9 @@ func NewVoid(hf HashFunc, maxSize int) Void {
 }
 
 func (s Void) Post(ctx context.Context, data []byte) (ID, error) {
+	if len(data) > s.MaxSize() {
+		return ID{}, ErrTooLarge
+	}
 	return s.Hash(data), nil
 }
 
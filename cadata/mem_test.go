@@ -12,3 +12,15 @@ func TestMemStore(t *testing.T) {
 		return cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize)
 	})
 }
+
+func TestVoid(t *testing.T) {
+	storetest.TestStoreCaps(t, storetest.Caps{}, func(t testing.TB) cadata.Store {
+		return cadata.NewVoid(cadata.DefaultHash, cadata.DefaultMaxSize)
+	})
+}
+
+func BenchmarkMemStore(b *testing.B) {
+	storetest.BenchmarkStore(b, func(t testing.TB) cadata.Store {
+		return cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize)
+	})
+}
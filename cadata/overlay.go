@@ -0,0 +1,196 @@
+package cadata
+
+import (
+	"context"
+)
+
+var _ Store = &Overlay{}
+
+// Overlay is a Store which layers a writable upper Store on top of a lower Store.
+// Reads check the upper layer, then the lower layer.
+// Posts only go to the upper layer.
+// Deletes remove data from the upper layer, and hide data in the lower layer by recording a whiteout.
+//
+// The upper and lower layers must use the same hash function.
+type Overlay struct {
+	lower, upper Store
+	whiteouts    Set
+}
+
+// NewOverlay returns an Overlay which reads from lower and upper, and writes to upper.
+// IDs deleted from the lower layer are recorded in whiteouts.
+func NewOverlay(lower, upper Store, whiteouts Set) *Overlay {
+	return &Overlay{
+		lower:     lower,
+		upper:     upper,
+		whiteouts: whiteouts,
+	}
+}
+
+func (o *Overlay) Post(ctx context.Context, data []byte) (ID, error) {
+	id, err := o.upper.Post(ctx, data)
+	if err != nil {
+		return ID{}, err
+	}
+	if err := o.whiteouts.Delete(ctx, id); err != nil {
+		return ID{}, err
+	}
+	return id, nil
+}
+
+func (o *Overlay) Get(ctx context.Context, id ID, buf []byte) (int, error) {
+	if yes, err := o.whiteouts.Exists(ctx, id); err != nil {
+		return 0, err
+	} else if yes {
+		return 0, ErrNotFound{Key: id}
+	}
+	n, err := o.upper.Get(ctx, id, buf)
+	if IsNotFound(err) {
+		return o.lower.Get(ctx, id, buf)
+	}
+	return n, err
+}
+
+func (o *Overlay) Exists(ctx context.Context, id ID) (bool, error) {
+	if yes, err := o.whiteouts.Exists(ctx, id); err != nil {
+		return false, err
+	} else if yes {
+		return false, nil
+	}
+	if yes, err := o.upper.Exists(ctx, id); err != nil || yes {
+		return yes, err
+	}
+	return o.lower.Exists(ctx, id)
+}
+
+// Delete removes id from the upper layer, and records a whiteout if the lower layer has id.
+func (o *Overlay) Delete(ctx context.Context, id ID) error {
+	if err := o.upper.Delete(ctx, id); err != nil {
+		return err
+	}
+	yes, err := o.lower.Exists(ctx, id)
+	if err != nil {
+		return err
+	}
+	if yes {
+		return o.whiteouts.Add(ctx, id)
+	}
+	return nil
+}
+
+// List merges the IDs from both layers in the order of span, omitting whiteouts.
+func (o *Overlay) List(ctx context.Context, span Span, ids []ID) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	upperBuf := make([]ID, len(ids))
+	lowerBuf := make([]ID, len(ids))
+	for {
+		un, err := o.upper.List(ctx, span, upperBuf)
+		if err != nil {
+			return 0, err
+		}
+		ln, err := o.lower.List(ctx, span, lowerBuf)
+		if err != nil {
+			return 0, err
+		}
+		if un == 0 && ln == 0 {
+			return 0, nil
+		}
+		// Each layer may have more IDs after the ones it returned,
+		// so only IDs up to the earliest last ID are known to be complete.
+		var last ID
+		switch {
+		case un == 0:
+			last = lowerBuf[ln-1]
+		case ln == 0:
+			last = upperBuf[un-1]
+		default:
+			last = upperBuf[un-1]
+			if compareIn(span, lowerBuf[ln-1], last) < 0 {
+				last = lowerBuf[ln-1]
+			}
+		}
+		merged := mergeIDs(span, upperBuf[:un], lowerBuf[:ln], last)
+		var n int
+		for _, id := range merged {
+			yes, err := o.whiteouts.Exists(ctx, id)
+			if err != nil {
+				return 0, err
+			}
+			if !yes {
+				ids[n] = id
+				n++
+			}
+		}
+		if n > 0 {
+			return n, nil
+		}
+		span = span.After(last)
+	}
+}
+
+func (o *Overlay) Hash(x []byte) ID {
+	return o.upper.Hash(x)
+}
+
+func (o *Overlay) MaxSize() int {
+	return o.upper.MaxSize()
+}
+
+// Commit copies the upper layer into the lower layer and deletes the whiteouts from the lower layer.
+// Then the upper layer and the whiteouts are cleared.
+func (o *Overlay) Commit(ctx context.Context) error {
+	if err := CopyAll(ctx, o.lower, o.upper); err != nil {
+		return err
+	}
+	if err := ForEach(ctx, o.whiteouts, Span{}, func(id ID) error {
+		return o.lower.Delete(ctx, id)
+	}); err != nil {
+		return err
+	}
+	return o.Discard(ctx)
+}
+
+// Discard clears the upper layer and the whiteouts, leaving the lower layer as it was.
+func (o *Overlay) Discard(ctx context.Context) error {
+	if err := DeleteAll(ctx, o.upper); err != nil {
+		return err
+	}
+	return DeleteAll(ctx, o.whiteouts)
+}
+
+// mergeIDs merges the slices a and b, sorted in the order of span, into a sorted slice without duplicates.
+// IDs after last are excluded.
+func mergeIDs(span Span, a, b []ID, last ID) (ret []ID) {
+	for len(a) > 0 || len(b) > 0 {
+		var next ID
+		switch {
+		case len(b) == 0:
+			next, a = a[0], a[1:]
+		case len(a) == 0:
+			next, b = b[0], b[1:]
+		default:
+			c := compareIn(span, a[0], b[0])
+			if c <= 0 {
+				next, a = a[0], a[1:]
+			}
+			if c >= 0 {
+				next, b = b[0], b[1:]
+			}
+		}
+		if compareIn(span, next, last) > 0 {
+			break
+		}
+		ret = append(ret, next)
+	}
+	return ret
+}
+
+// compareIn compares a and b in the order that span is iterated.
+func compareIn(span Span, a, b ID) int {
+	if span.IsDesc() {
+		return b.Compare(a)
+	}
+	return a.Compare(b)
+}
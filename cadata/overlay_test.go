@@ -0,0 +1,126 @@
+package cadata_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.brendoncarroll.net/state/cadata"
+	"go.brendoncarroll.net/state/cadata/storetest"
+)
+
+func TestOverlay(t *testing.T) {
+	storetest.TestStore(t, func(t testing.TB) cadata.Store {
+		return newTestOverlay(cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize))
+	})
+	ctx := context.Background()
+	post := func(t testing.TB, s cadata.Poster, data string) cadata.ID {
+		id, err := s.Post(ctx, []byte(data))
+		require.NoError(t, err)
+		return id
+	}
+	listAll := func(t testing.TB, s cadata.Lister) (ret []cadata.ID) {
+		require.NoError(t, cadata.ForEach(ctx, s, cadata.Span{}, func(id cadata.ID) error {
+			ret = append(ret, id)
+			return nil
+		}))
+		return ret
+	}
+	setup := func(t testing.TB) (lower *cadata.MemStore, o *cadata.Overlay, lowerIDs, upperIDs []cadata.ID) {
+		lower = cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize)
+		o = newTestOverlay(lower)
+		for _, x := range []string{"a", "b", "c"} {
+			lowerIDs = append(lowerIDs, post(t, lower, x))
+		}
+		for _, x := range []string{"1", "2"} {
+			upperIDs = append(upperIDs, post(t, o, x))
+		}
+		return lower, o, lowerIDs, upperIDs
+	}
+	t.Run("ReadThrough", func(t *testing.T) {
+		lower, o, lowerIDs, upperIDs := setup(t)
+		data, err := cadata.GetBytes(ctx, o, lowerIDs[0])
+		require.NoError(t, err)
+		require.Equal(t, "a", string(data))
+		require.Len(t, listAll(t, o), 5)
+		require.Equal(t, 3, lower.Len())
+		yes, err := lower.Exists(ctx, upperIDs[0])
+		require.NoError(t, err)
+		require.False(t, yes)
+	})
+	t.Run("Whiteout", func(t *testing.T) {
+		lower, o, lowerIDs, _ := setup(t)
+		require.NoError(t, o.Delete(ctx, lowerIDs[1]))
+		yes, err := o.Exists(ctx, lowerIDs[1])
+		require.NoError(t, err)
+		require.False(t, yes)
+		require.NotContains(t, listAll(t, o), lowerIDs[1])
+		require.Len(t, listAll(t, o), 4)
+		require.Equal(t, 3, lower.Len())
+
+		// posting the data again removes the whiteout
+		post(t, o, "b")
+		require.Contains(t, listAll(t, o), lowerIDs[1])
+	})
+	t.Run("ListMerge", func(t *testing.T) {
+		lower := cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize)
+		o := newTestOverlay(lower)
+		expected := map[cadata.ID]struct{}{}
+		for i := 0; i < 30; i++ {
+			data := fmt.Sprint(i)
+			var id cadata.ID
+			if i < 20 {
+				id = post(t, lower, data)
+			}
+			if i >= 15 {
+				id = post(t, o, data)
+			}
+			expected[id] = struct{}{}
+		}
+		for i := 0; i < 20; i += 2 {
+			id := cadata.DefaultHash([]byte(fmt.Sprint(i)))
+			require.NoError(t, o.Delete(ctx, id))
+			delete(expected, id)
+		}
+		var actual []cadata.ID
+		span := cadata.Span{}
+		buf := make([]cadata.ID, 3)
+		for {
+			n, err := o.List(ctx, span, buf)
+			require.NoError(t, err)
+			if n == 0 {
+				break
+			}
+			actual = append(actual, buf[:n]...)
+			span = span.WithLowerExcl(buf[n-1])
+		}
+		require.Len(t, actual, len(expected))
+		for i := range actual {
+			require.Contains(t, expected, actual[i])
+			if i > 0 {
+				require.Less(t, actual[i-1].Compare(actual[i]), 0)
+			}
+		}
+	})
+	t.Run("Commit", func(t *testing.T) {
+		lower, o, lowerIDs, upperIDs := setup(t)
+		require.NoError(t, o.Delete(ctx, lowerIDs[0]))
+		require.NoError(t, o.Commit(ctx))
+		require.ElementsMatch(t, append(lowerIDs[1:], upperIDs...), listAll(t, lower))
+		require.ElementsMatch(t, listAll(t, lower), listAll(t, o))
+	})
+	t.Run("Discard", func(t *testing.T) {
+		lower, o, lowerIDs, _ := setup(t)
+		require.NoError(t, o.Delete(ctx, lowerIDs[0]))
+		require.NoError(t, o.Discard(ctx))
+		require.ElementsMatch(t, lowerIDs, listAll(t, lower))
+		require.ElementsMatch(t, lowerIDs, listAll(t, o))
+	})
+}
+
+func newTestOverlay(lower cadata.Store) *cadata.Overlay {
+	upper := cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize)
+	return cadata.NewOverlay(lower, upper, cadata.NewMemSet())
+}
@@ -0,0 +1,121 @@
+package cadata
+
+import (
+	"context"
+	"math/big"
+	"runtime"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// SplitSpan divides span into at most n contiguous, non-overlapping Spans of approximately equal width.
+// The Spans are returned in the order of span, and together they contain exactly the IDs in span.
+// If span is descending, so is each of the returned Spans.
+// IDs produced by a cryptographic hash are uniformly distributed, so each Span will contain about the same number of them.
+// If span excludes the maximum ID as its lower bound, it is empty, and SplitSpan returns nil.
+func SplitSpan(span Span, n int) []Span {
+	if n < 1 {
+		n = 1
+	}
+	if l, ok := span.LowerBound(); ok && !span.IncludesLower() && l.Successor().IsZero() {
+		return nil
+	}
+	lower := BeginFromSpan(span)
+	begin := new(big.Int).SetBytes(lower[:])
+	end := new(big.Int).Lsh(big.NewInt(1), IDSize*8)
+	endID, hasEnd := EndFromSpan(span)
+	if hasEnd {
+		end.SetBytes(endID[:])
+	}
+	width := new(big.Int).Sub(end, begin)
+	if width.Sign() <= 0 {
+		return []Span{span}
+	}
+
+	var ret []Span
+	for i := 1; i < n; i++ {
+		// begin + width * i / n
+		x := new(big.Int).Mul(width, big.NewInt(int64(i)))
+		x.Quo(x, big.NewInt(int64(n)))
+		x.Add(x, begin)
+		var upper ID
+		x.FillBytes(upper[:])
+		if upper == lower {
+			continue
+		}
+		ret = append(ret, Span{}.WithLowerIncl(lower).WithUpperExcl(upper))
+		lower = upper
+	}
+	last := Span{}.WithLowerIncl(lower)
+	if hasEnd {
+		last = last.WithUpperExcl(endID)
+	}
+	ret = append(ret, last)
+	if span.IsDesc() {
+		for i, j := 0, len(ret)-1; i < j; i, j = i+1, j-1 {
+			ret[i], ret[j] = ret[j], ret[i]
+		}
+		for i := range ret {
+			ret[i] = ret[i].Desc()
+		}
+	}
+	return ret
+}
+
+type ForEachParams struct {
+	// Parallelism is the number of Spans listed and processed concurrently.
+	// If Parallelism is 0, GOMAXPROCS is used.
+	Parallelism int
+	// Ordered causes fn to be called from a single goroutine, with IDs in the order of the span.
+	// Listing is still done concurrently.
+	// If Ordered is false, fn is called concurrently, and IDs are only in order within each Span.
+	Ordered bool
+}
+
+// ParallelForEach calls fn with every ID in span.
+// span is divided using SplitSpan, and each part is listed concurrently.
+func ParallelForEach(ctx context.Context, s Lister, span Span, params ForEachParams, fn func(ID) error) error {
+	if params.Parallelism <= 0 {
+		params.Parallelism = runtime.GOMAXPROCS(0)
+	}
+	spans := SplitSpan(span, params.Parallelism)
+	eg, ctx := errgroup.WithContext(ctx)
+	if !params.Ordered {
+		for _, span := range spans {
+			span := span
+			eg.Go(func() error {
+				return ForEach(ctx, s, span, fn)
+			})
+		}
+		return eg.Wait()
+	}
+
+	const chanSize = 64
+	chans := make([]chan ID, len(spans))
+	for i, span := range spans {
+		i, span := i, span
+		chans[i] = make(chan ID, chanSize)
+		eg.Go(func() error {
+			defer close(chans[i])
+			return ForEach(ctx, s, span, func(id ID) error {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case chans[i] <- id:
+					return nil
+				}
+			})
+		})
+	}
+	eg.Go(func() error {
+		for _, ch := range chans {
+			for id := range ch {
+				if err := fn(id); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return eg.Wait()
+}
@@ -0,0 +1,118 @@
+package cadata_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.brendoncarroll.net/state"
+	"go.brendoncarroll.net/state/cadata"
+)
+
+func TestSplitSpan(t *testing.T) {
+	ids := make([]cadata.ID, 1000)
+	for i := range ids {
+		ids[i] = cadata.DefaultHash([]byte(strconv.Itoa(i)))
+	}
+	a, b := ids[0], ids[1]
+	if a.Compare(b) > 0 {
+		a, b = b, a
+	}
+	var max cadata.ID
+	for i := range max {
+		max[i] = 0xff
+	}
+	for _, span := range []cadata.Span{
+		{},
+		cadata.Span{}.WithLowerIncl(a),
+		cadata.Span{}.WithLowerExcl(a),
+		cadata.Span{}.WithUpperIncl(b),
+		cadata.Span{}.WithUpperExcl(b),
+		cadata.Span{}.WithLowerExcl(a).WithUpperIncl(b),
+		cadata.Span{}.WithUpperIncl(max),
+		cadata.Span{}.WithLowerExcl(max),
+		state.PointSpan(a),
+	} {
+		for _, n := range []int{1, 2, 3, 7, 16} {
+			parts := cadata.SplitSpan(span, n)
+			require.LessOrEqual(t, len(parts), n)
+			for _, id := range append(ids, a, b, a.Successor(), b.Successor(), cadata.ID{}, max) {
+				var count int
+				for _, part := range parts {
+					if part.Contains(id, compareIDs) {
+						count++
+					}
+				}
+				if span.Contains(id, compareIDs) {
+					require.Equal(t, 1, count, "span=%v n=%d id=%v", span, n, id)
+				} else {
+					require.Equal(t, 0, count, "span=%v n=%d id=%v", span, n, id)
+				}
+			}
+		}
+	}
+	require.Len(t, cadata.SplitSpan(state.PointSpan(a), 4), 1)
+	require.Len(t, cadata.SplitSpan(cadata.Span{}, 4), 4)
+	// there is nothing after the maximum ID.
+	require.Empty(t, cadata.SplitSpan(cadata.Span{}.WithLowerExcl(max), 4))
+}
+
+func TestParallelForEach(t *testing.T) {
+	ctx := context.Background()
+	s := cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize)
+	var expected []cadata.ID
+	for i := 0; i < 1000; i++ {
+		id, err := s.Post(ctx, []byte(strconv.Itoa(i)))
+		require.NoError(t, err)
+		expected = append(expected, id)
+	}
+	sortIDs(expected)
+
+	t.Run("Unordered", func(t *testing.T) {
+		var mu sync.Mutex
+		var actual []cadata.ID
+		err := cadata.ParallelForEach(ctx, s, cadata.Span{}, cadata.ForEachParams{Parallelism: 8}, func(id cadata.ID) error {
+			mu.Lock()
+			defer mu.Unlock()
+			actual = append(actual, id)
+			return nil
+		})
+		require.NoError(t, err)
+		sortIDs(actual)
+		require.Equal(t, expected, actual)
+	})
+	t.Run("Ordered", func(t *testing.T) {
+		var actual []cadata.ID
+		err := cadata.ParallelForEach(ctx, s, cadata.Span{}, cadata.ForEachParams{Parallelism: 8, Ordered: true}, func(id cadata.ID) error {
+			actual = append(actual, id)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, expected, actual)
+	})
+	t.Run("OrderedDesc", func(t *testing.T) {
+		var actual []cadata.ID
+		err := cadata.ParallelForEach(ctx, s, cadata.Span{}.Desc(), cadata.ForEachParams{Parallelism: 8, Ordered: true}, func(id cadata.ID) error {
+			actual = append(actual, id)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, actual, len(expected))
+		for i := range actual {
+			require.Equal(t, expected[len(expected)-1-i], actual[i])
+		}
+	})
+	t.Run("Span", func(t *testing.T) {
+		span := cadata.Span{}.WithLowerExcl(expected[10]).WithUpperIncl(expected[20])
+		var actual []cadata.ID
+		err := cadata.ParallelForEach(ctx, s, span, cadata.ForEachParams{Parallelism: 3, Ordered: true}, func(id cadata.ID) error {
+			actual = append(actual, id)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, expected[11:21], actual)
+	})
+}
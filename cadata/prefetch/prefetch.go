@@ -0,0 +1,206 @@
+// Package prefetch provides a cadata.Getter which deduplicates concurrent reads, and can read ahead into a cache.
+package prefetch
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+
+	"golang.org/x/sync/semaphore"
+
+	"go.brendoncarroll.net/state/cadata"
+)
+
+// DefaultCacheSize is the number of bytes cached, if Params.CacheSize is 0.
+const DefaultCacheSize = 64 << 20
+
+type Params struct {
+	// Inner is the Getter to read from.
+	Inner cadata.Getter
+	// CacheSize is the maximum total size in bytes of blobs held in the cache.
+	CacheSize int
+	// Parallelism is the maximum number of concurrent reads made by Prefetch.
+	// If Parallelism is 0, GOMAXPROCS is used.
+	Parallelism int
+}
+
+var _ cadata.Getter = &Getter{}
+
+// Getter wraps a cadata.Getter.
+// Concurrent calls to Get for the same ID result in a single call to the inner Getter.
+// Blobs read by Get or Prefetch are held in a bounded LRU cache.
+type Getter struct {
+	inner     cadata.Getter
+	cacheSize int
+	sem       *semaphore.Weighted
+	pool      sync.Pool
+
+	mu       sync.Mutex
+	inflight map[cadata.ID]*call
+	cache    map[cadata.ID]*list.Element
+	lru      list.List
+	size     int
+}
+
+func New(params Params) *Getter {
+	if params.CacheSize <= 0 {
+		params.CacheSize = DefaultCacheSize
+	}
+	if params.Parallelism <= 0 {
+		params.Parallelism = runtime.GOMAXPROCS(0)
+	}
+	maxSize := params.Inner.MaxSize()
+	return &Getter{
+		inner:     params.Inner,
+		cacheSize: params.CacheSize,
+		sem:       semaphore.NewWeighted(int64(params.Parallelism)),
+		pool: sync.Pool{
+			New: func() any {
+				buf := make([]byte, maxSize)
+				return &buf
+			},
+		},
+		inflight: make(map[cadata.ID]*call),
+		cache:    make(map[cadata.ID]*list.Element),
+	}
+}
+
+func (g *Getter) Get(ctx context.Context, id cadata.ID, buf []byte) (int, error) {
+	for {
+		data, shared, err := g.get(ctx, id)
+		if shared && isContextErr(err) && ctx.Err() == nil {
+			// the read was started by a caller which has since given up, try again.
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if len(data) > len(buf) {
+			return 0, io.ErrShortBuffer
+		}
+		return copy(buf, data), nil
+	}
+}
+
+// Prefetch starts reading ids into the cache in the background, and returns immediately.
+// Reads are made in order, with at most Params.Parallelism in flight across all calls to Prefetch.
+// Reads which have not started when ctx is cancelled are abandoned.
+func (g *Getter) Prefetch(ctx context.Context, ids ...cadata.ID) {
+	ids = append([]cadata.ID{}, ids...)
+	go func() {
+		for _, id := range ids {
+			if g.isCached(id) {
+				continue
+			}
+			if err := g.sem.Acquire(ctx, 1); err != nil {
+				return
+			}
+			if ctx.Err() != nil {
+				// Acquire can succeed after ctx is cancelled, if there is capacity.
+				g.sem.Release(1)
+				return
+			}
+			go func(id cadata.ID) {
+				defer g.sem.Release(1)
+				g.get(ctx, id)
+			}(id)
+		}
+	}()
+}
+
+func (g *Getter) Hash(x []byte) cadata.ID {
+	return g.inner.Hash(x)
+}
+
+func (g *Getter) MaxSize() int {
+	return g.inner.MaxSize()
+}
+
+type call struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// get returns the data for id from the cache, an in flight call, or by starting a new call.
+// shared is true if the result came from a call started by someone else.
+// The returned slice must not be modified.
+func (g *Getter) get(ctx context.Context, id cadata.ID) (_ []byte, shared bool, _ error) {
+	g.mu.Lock()
+	if e, ok := g.cache[id]; ok {
+		g.lru.MoveToFront(e)
+		data := e.Value.(*cacheEntry).data
+		g.mu.Unlock()
+		return data, false, nil
+	}
+	c, ok := g.inflight[id]
+	if !ok {
+		c = &call{done: make(chan struct{})}
+		g.inflight[id] = c
+	}
+	g.mu.Unlock()
+
+	if ok {
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case <-c.done:
+			return c.data, true, c.err
+		}
+	}
+
+	bufp := g.pool.Get().(*[]byte)
+	n, err := g.inner.Get(ctx, id, *bufp)
+	if err == nil {
+		c.data = append([]byte{}, (*bufp)[:n]...)
+	}
+	c.err = err
+	g.pool.Put(bufp)
+
+	g.mu.Lock()
+	delete(g.inflight, id)
+	if err == nil {
+		g.addToCache(id, c.data)
+	}
+	g.mu.Unlock()
+	close(c.done)
+	return c.data, false, c.err
+}
+
+type cacheEntry struct {
+	id   cadata.ID
+	data []byte
+}
+
+// addToCache adds data to the cache, evicting the least recently used entries to stay within the size limit.
+// g.mu must be held.
+func (g *Getter) addToCache(id cadata.ID, data []byte) {
+	if len(data) > g.cacheSize {
+		return
+	}
+	if _, exists := g.cache[id]; exists {
+		return
+	}
+	g.cache[id] = g.lru.PushFront(&cacheEntry{id: id, data: data})
+	g.size += len(data)
+	for g.size > g.cacheSize {
+		e := g.lru.Back()
+		ent := g.lru.Remove(e).(*cacheEntry)
+		delete(g.cache, ent.id)
+		g.size -= len(ent.data)
+	}
+}
+
+func (g *Getter) isCached(id cadata.ID) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, ok := g.cache[id]
+	return ok
+}
+
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,161 @@
+package prefetch
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go.brendoncarroll.net/state/cadata"
+)
+
+func TestSingleflight(t *testing.T) {
+	ctx := context.Background()
+	inner, ids := newTestStore(t, 1)
+	gate := make(chan struct{})
+	cg := &countingGetter{Getter: inner, gate: gate}
+	g := New(Params{Inner: cg})
+
+	const n = 10
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := cadata.GetBytes(ctx, g, ids[0])
+			require.NoError(t, err)
+			require.Equal(t, "0", string(data))
+		}()
+	}
+	require.Eventually(t, func() bool { return cg.started() > 0 }, time.Second, time.Millisecond)
+	close(gate)
+	wg.Wait()
+	require.Equal(t, int64(1), cg.started())
+}
+
+func TestCancelledCaller(t *testing.T) {
+	inner, ids := newTestStore(t, 1)
+	gate := make(chan struct{})
+	cg := &countingGetter{Getter: inner, gate: gate}
+	g := New(Params{Inner: cg})
+
+	// the first caller gives up, which must not fail the second.
+	ctx1, cf := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := cadata.GetBytes(ctx1, g, ids[0])
+		errs <- err
+	}()
+	require.Eventually(t, func() bool { return cg.started() > 0 }, time.Second, time.Millisecond)
+	done := make(chan []byte)
+	go func() {
+		data, err := cadata.GetBytes(context.Background(), g, ids[0])
+		require.NoError(t, err)
+		done <- data
+	}()
+	cf()
+	require.ErrorIs(t, <-errs, context.Canceled)
+	close(gate)
+	require.Equal(t, "0", string(<-done))
+}
+
+func TestPrefetch(t *testing.T) {
+	ctx := context.Background()
+	inner, ids := newTestStore(t, 100)
+	cg := &countingGetter{Getter: inner}
+	g := New(Params{Inner: cg, Parallelism: 4})
+
+	g.Prefetch(ctx, ids...)
+	require.Eventually(t, func() bool {
+		for _, id := range ids {
+			if !g.isCached(id) {
+				return false
+			}
+		}
+		return true
+	}, time.Second, time.Millisecond)
+	for i, id := range ids {
+		data, err := cadata.GetBytes(ctx, g, id)
+		require.NoError(t, err)
+		require.Equal(t, strconv.Itoa(i), string(data))
+	}
+	require.Equal(t, int64(len(ids)), cg.started())
+}
+
+func TestPrefetchCancel(t *testing.T) {
+	inner, ids := newTestStore(t, 100)
+	gate := make(chan struct{})
+	cg := &countingGetter{Getter: inner, gate: gate}
+	g := New(Params{Inner: cg, Parallelism: 2})
+
+	ctx, cf := context.WithCancel(context.Background())
+	g.Prefetch(ctx, ids...)
+	require.Eventually(t, func() bool { return cg.started() == 2 }, time.Second, time.Millisecond)
+	cf()
+	close(gate)
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, int64(2), cg.started())
+}
+
+func TestCacheBounded(t *testing.T) {
+	ctx := context.Background()
+	inner, ids := newTestStore(t, 100)
+	g := New(Params{Inner: inner, CacheSize: 10})
+	for _, id := range ids {
+		_, err := cadata.GetBytes(ctx, g, id)
+		require.NoError(t, err)
+		g.mu.Lock()
+		require.LessOrEqual(t, g.size, 10)
+		g.mu.Unlock()
+	}
+	require.True(t, g.isCached(ids[len(ids)-1]))
+	require.False(t, g.isCached(ids[0]))
+}
+
+func TestShortBuffer(t *testing.T) {
+	ctx := context.Background()
+	inner, ids := newTestStore(t, 11)
+	g := New(Params{Inner: inner})
+	_, err := g.Get(ctx, ids[10], make([]byte, 1))
+	require.ErrorIs(t, err, io.ErrShortBuffer)
+}
+
+func newTestStore(t testing.TB, n int) (*cadata.MemStore, []cadata.ID) {
+	ctx := context.Background()
+	s := cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize)
+	var ids []cadata.ID
+	for i := 0; i < n; i++ {
+		id, err := s.Post(ctx, []byte(strconv.Itoa(i)))
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	return s, ids
+}
+
+// countingGetter counts calls to Get, and blocks them until gate is closed, if it is not nil.
+type countingGetter struct {
+	cadata.Getter
+	gate  chan struct{}
+	count int64
+}
+
+func (g *countingGetter) Get(ctx context.Context, id cadata.ID, buf []byte) (int, error) {
+	atomic.AddInt64(&g.count, 1)
+	if g.gate != nil {
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-g.gate:
+		}
+	}
+	return g.Getter.Get(ctx, id, buf)
+}
+
+func (g *countingGetter) started() int64 {
+	return atomic.LoadInt64(&g.count)
+}
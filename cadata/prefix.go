@@ -0,0 +1,158 @@
+package cadata
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// encodedIDLen is the length of an ID encoded using Base64Alphabet
+const encodedIDLen = (IDSize*8 + 5) / 6
+
+// maxCandidates is the maximum number of IDs included in ErrAmbiguousPrefix
+const maxCandidates = 16
+
+// ErrAmbiguousPrefix is returned by ResolvePrefix when more than one ID has the prefix.
+type ErrAmbiguousPrefix struct {
+	Prefix string
+	// Candidates holds some of the IDs which have the prefix.
+	Candidates []ID
+}
+
+func (e ErrAmbiguousPrefix) Error() string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "prefix %q is ambiguous, candidates:", e.Prefix)
+	for _, id := range e.Candidates {
+		sb.WriteString(" ")
+		sb.WriteString(id.String())
+	}
+	return sb.String()
+}
+
+// ErrNoPrefixMatch is returned by ResolvePrefix when no ID has the prefix.
+type ErrNoPrefixMatch struct {
+	Prefix string
+}
+
+func (e ErrNoPrefixMatch) Error() string {
+	return fmt.Sprintf("no ID has prefix %q", e.Prefix)
+}
+
+// PrefixSpan returns the smallest Span containing every ID whose base64 encoding starts with prefix.
+func PrefixSpan(prefix string) (Span, error) {
+	lower, err := decodePadded(prefix)
+	if err != nil {
+		return Span{}, err
+	}
+	span := Span{}.WithLowerIncl(lower)
+	if len(prefix) == encodedIDLen {
+		return span.WithUpperIncl(lower), nil
+	}
+	// increment the prefix, as a base64 number, to get the exclusive upper bound.
+	last := Base64Alphabet[len(Base64Alphabet)-1]
+	next := []byte(prefix)
+	for len(next) > 0 && next[len(next)-1] == last {
+		next = next[:len(next)-1]
+	}
+	if len(next) == 0 {
+		return span, nil
+	}
+	i := strings.IndexByte(Base64Alphabet, next[len(next)-1])
+	next[len(next)-1] = Base64Alphabet[i+1]
+	upper, err := decodePadded(string(next))
+	if err != nil {
+		return Span{}, err
+	}
+	return span.WithUpperExcl(upper), nil
+}
+
+// ResolvePrefix returns the only ID in s whose base64 encoding starts with prefix.
+// If no ID has the prefix, ErrNoPrefixMatch is returned.
+// If more than one ID has the prefix, ErrAmbiguousPrefix is returned.
+func ResolvePrefix(ctx context.Context, s Lister, prefix string) (ID, error) {
+	span, err := PrefixSpan(prefix)
+	if err != nil {
+		return ID{}, err
+	}
+	var buf [maxCandidates]ID
+	n, err := s.List(ctx, span, buf[:])
+	if err != nil {
+		return ID{}, err
+	}
+	switch n {
+	case 0:
+		return ID{}, ErrNoPrefixMatch{Prefix: prefix}
+	case 1:
+		// List may return fewer than len(buf) without being at the end.
+		more, err := s.List(ctx, span.WithLowerExcl(buf[0]), buf[1:2])
+		if err != nil {
+			return ID{}, err
+		}
+		if more == 0 {
+			return buf[0], nil
+		}
+		n = 2
+	}
+	return ID{}, ErrAmbiguousPrefix{
+		Prefix:     prefix,
+		Candidates: append([]ID{}, buf[:n]...),
+	}
+}
+
+// ShortestUniquePrefix returns the shortest prefix of id's base64 encoding, which no other ID in s starts with.
+// It is suitable for displaying IDs in an abbreviated form, which can be turned back into IDs with ResolvePrefix.
+func ShortestUniquePrefix(ctx context.Context, s Lister, id ID) (string, error) {
+	full := id.String()
+	// isUnique is monotonic in the prefix length, so binary search for the shortest.
+	lo, hi := 1, encodedIDLen
+	for lo < hi {
+		mid := (lo + hi) / 2
+		yes, err := isUniquePrefix(ctx, s, id, full[:mid])
+		if err != nil {
+			return "", err
+		}
+		if yes {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return full[:lo], nil
+}
+
+// isUniquePrefix returns true if no ID other than id has prefix.
+func isUniquePrefix(ctx context.Context, s Lister, id ID, prefix string) (bool, error) {
+	span, err := PrefixSpan(prefix)
+	if err != nil {
+		return false, err
+	}
+	for {
+		var buf [2]ID
+		n, err := s.List(ctx, span, buf[:])
+		if err != nil {
+			return false, err
+		}
+		if n == 0 {
+			return true, nil
+		}
+		for _, x := range buf[:n] {
+			if x != id {
+				return false, nil
+			}
+		}
+		span = span.WithLowerExcl(buf[n-1])
+	}
+}
+
+// decodePadded decodes prefix, with the rest of the ID filled with zeros.
+func decodePadded(prefix string) (ID, error) {
+	if len(prefix) > encodedIDLen {
+		return ID{}, fmt.Errorf("prefix is longer than an ID: %q", prefix)
+	}
+	padded := prefix + strings.Repeat(Base64Alphabet[:1], encodedIDLen-len(prefix))
+	var id ID
+	if _, err := enc.Decode(id[:], []byte(padded)); err != nil {
+		return ID{}, fmt.Errorf("invalid ID prefix %q: %w", prefix, err)
+	}
+	return id, nil
+}
@@ -0,0 +1,73 @@
+package cadata_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.brendoncarroll.net/state/cadata"
+)
+
+func TestPrefixSpan(t *testing.T) {
+	ids := make([]cadata.ID, 100)
+	for i := range ids {
+		ids[i] = cadata.DefaultHash([]byte(strconv.Itoa(i)))
+	}
+	for _, id := range ids {
+		s := id.String()
+		for _, n := range []int{0, 1, 2, 5, len(s)} {
+			span, err := cadata.PrefixSpan(s[:n])
+			require.NoError(t, err)
+			require.True(t, span.Contains(id, compareIDs), "prefix=%s", s[:n])
+		}
+		span, err := cadata.PrefixSpan(s)
+		require.NoError(t, err)
+		require.False(t, span.Contains(id.Successor(), compareIDs))
+	}
+	span, err := cadata.PrefixSpan("zz")
+	require.NoError(t, err)
+	_, hasUpper := span.UpperBound()
+	require.False(t, hasUpper)
+
+	_, err = cadata.PrefixSpan("!")
+	require.Error(t, err)
+}
+
+func TestResolvePrefix(t *testing.T) {
+	ctx := context.Background()
+	s := cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize)
+	var ids []cadata.ID
+	for i := 0; i < 1000; i++ {
+		id, err := s.Post(ctx, []byte(strconv.Itoa(i)))
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	for _, id := range ids {
+		p, err := cadata.ShortestUniquePrefix(ctx, s, id)
+		require.NoError(t, err)
+		actual, err := cadata.ResolvePrefix(ctx, s, p)
+		require.NoError(t, err)
+		require.Equal(t, id, actual)
+
+		if len(p) > 1 {
+			_, err := cadata.ResolvePrefix(ctx, s, p[:len(p)-1])
+			var ambErr cadata.ErrAmbiguousPrefix
+			require.True(t, errors.As(err, &ambErr), "%v", err)
+			require.GreaterOrEqual(t, len(ambErr.Candidates), 2)
+		}
+	}
+
+	missing := cadata.DefaultHash([]byte("missing"))
+	_, err := cadata.ResolvePrefix(ctx, s, missing.String())
+	require.ErrorAs(t, err, &cadata.ErrNoPrefixMatch{})
+
+	_, err = cadata.ResolvePrefix(ctx, s, "")
+	require.ErrorAs(t, err, &cadata.ErrAmbiguousPrefix{})
+}
+
+func compareIDs(a, b cadata.ID) int {
+	return a.Compare(b)
+}
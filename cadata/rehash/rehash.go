@@ -0,0 +1,137 @@
+// Package rehash migrates data between stores which use different hash functions.
+//
+// Migrate copies blobs into a store using the new hash function, and records the ID
+// each blob had under the old hash function.
+// Store resolves old IDs using that mapping, so existing references keep working while they are rewritten.
+package rehash
+
+import (
+	"context"
+
+	"go.brendoncarroll.net/state"
+	"go.brendoncarroll.net/state/cadata"
+	"go.brendoncarroll.net/state/kv"
+)
+
+// Mapping maps IDs from the old hash function to IDs from the new hash function.
+type Mapping = kv.Store[cadata.ID, cadata.ID]
+
+type Params struct {
+	// Src holds the blobs, identified by the old hash function.
+	Src cadata.GetLister
+	// Dst is where the blobs are copied, it determines the new hash function.
+	Dst cadata.Poster
+	// Mapping is where the old -> new ID pairs are written.
+	Mapping Mapping
+}
+
+// Migrate copies every blob in span from Src into Dst, and records the new ID of each in Mapping.
+// Blobs which already have an entry in Mapping are skipped, so Migrate can be called again with the same
+// span to resume after an interruption, or called with disjoint spans to split up the work.
+// The data read from Src is checked against the old ID.
+// Migrate returns the number of blobs copied.
+func Migrate(ctx context.Context, p Params, span cadata.Span) (int, error) {
+	var count int
+	buf := make([]byte, p.Src.MaxSize())
+	err := cadata.ForEach(ctx, p.Src, span, func(oldID cadata.ID) error {
+		yes, err := p.Mapping.Exists(ctx, oldID)
+		if err != nil {
+			return err
+		}
+		if yes {
+			return nil
+		}
+		n, err := p.Src.Get(ctx, oldID, buf)
+		if err != nil {
+			return err
+		}
+		if err := cadata.Check(p.Src.Hash, oldID, buf[:n]); err != nil {
+			return err
+		}
+		newID, err := p.Dst.Post(ctx, buf[:n])
+		if err != nil {
+			return err
+		}
+		// the mapping is written last, so a blob is never marked as migrated without being in Dst.
+		if err := p.Mapping.Put(ctx, oldID, newID); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+var _ cadata.Store = &Store{}
+
+// Store is a cadata.Store using the new hash function, which also accepts IDs from the old hash function.
+// Get, Exists and Delete resolve old IDs through the mapping.
+// Post and List only deal with new IDs.
+type Store struct {
+	cadata.Store
+	mapping kv.Getter[cadata.ID, cadata.ID]
+}
+
+// NewStore returns a Store, which serves data from inner, resolving old IDs using mapping.
+func NewStore(inner cadata.Store, mapping kv.Getter[cadata.ID, cadata.ID]) *Store {
+	return &Store{Store: inner, mapping: mapping}
+}
+
+func (s *Store) Get(ctx context.Context, id cadata.ID, buf []byte) (int, error) {
+	n, err := s.Store.Get(ctx, id, buf)
+	if !cadata.IsNotFound(err) {
+		return n, err
+	}
+	newID, ok, err2 := s.lookup(ctx, id)
+	if err2 != nil {
+		return 0, err2
+	}
+	if !ok {
+		return 0, err
+	}
+	return s.Store.Get(ctx, newID, buf)
+}
+
+func (s *Store) Exists(ctx context.Context, id cadata.ID) (bool, error) {
+	yes, err := s.Store.Exists(ctx, id)
+	if err != nil || yes {
+		return yes, err
+	}
+	newID, ok, err := s.lookup(ctx, id)
+	if err != nil || !ok {
+		return false, err
+	}
+	return s.Store.Exists(ctx, newID)
+}
+
+func (s *Store) Delete(ctx context.Context, id cadata.ID) error {
+	id, err := s.Resolve(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.Store.Delete(ctx, id)
+}
+
+// Resolve returns the new ID for id, if it is an old ID in the mapping.
+// Otherwise id is returned unchanged.
+func (s *Store) Resolve(ctx context.Context, id cadata.ID) (cadata.ID, error) {
+	newID, ok, err := s.lookup(ctx, id)
+	if err != nil {
+		return cadata.ID{}, err
+	}
+	if ok {
+		return newID, nil
+	}
+	return id, nil
+}
+
+func (s *Store) lookup(ctx context.Context, oldID cadata.ID) (cadata.ID, bool, error) {
+	var newID cadata.ID
+	if err := s.mapping.Get(ctx, oldID, &newID); err != nil {
+		if state.IsErrNotFound[cadata.ID](err) {
+			return cadata.ID{}, false, nil
+		}
+		return cadata.ID{}, false, err
+	}
+	return newID, true, nil
+}
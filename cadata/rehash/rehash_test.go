@@ -0,0 +1,106 @@
+package rehash
+
+import (
+	"context"
+	"crypto/sha256"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.brendoncarroll.net/state/cadata"
+	"go.brendoncarroll.net/state/cadata/storetest"
+	"go.brendoncarroll.net/state/kv"
+)
+
+func TestStore(t *testing.T) {
+	storetest.TestStore(t, func(t testing.TB) cadata.Store {
+		return NewStore(newDst(), newMapping())
+	})
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	src := cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize)
+	var oldIDs []cadata.ID
+	for i := 0; i < 100; i++ {
+		id, err := src.Post(ctx, []byte(strconv.Itoa(i)))
+		require.NoError(t, err)
+		oldIDs = append(oldIDs, id)
+	}
+	dst := newDst()
+	mapping := newMapping()
+	p := Params{Src: src, Dst: dst, Mapping: mapping}
+
+	// migrate the first half, then resume over everything.
+	mid := cadata.DefaultHash([]byte("split"))
+	n, err := Migrate(ctx, p, cadata.Span{}.WithUpperExcl(mid))
+	require.NoError(t, err)
+	require.Greater(t, n, 0)
+	n2, err := Migrate(ctx, p, cadata.Span{})
+	require.NoError(t, err)
+	require.Equal(t, len(oldIDs), n+n2)
+	n3, err := Migrate(ctx, p, cadata.Span{})
+	require.NoError(t, err)
+	require.Equal(t, 0, n3)
+	require.Equal(t, len(oldIDs), dst.Len())
+
+	s := NewStore(dst, mapping)
+	for i, oldID := range oldIDs {
+		data, err := cadata.GetBytes(ctx, s, oldID)
+		require.NoError(t, err)
+		require.Equal(t, strconv.Itoa(i), string(data))
+
+		yes, err := s.Exists(ctx, oldID)
+		require.NoError(t, err)
+		require.True(t, yes)
+
+		newID, err := s.Resolve(ctx, oldID)
+		require.NoError(t, err)
+		require.Equal(t, s.Hash(data), newID)
+		data2, err := cadata.GetBytes(ctx, s, newID)
+		require.NoError(t, err)
+		require.Equal(t, data, data2)
+	}
+
+	require.NoError(t, s.Delete(ctx, oldIDs[0]))
+	_, err = cadata.GetBytes(ctx, s, oldIDs[0])
+	require.True(t, cadata.IsNotFound(err))
+}
+
+func TestMigrateBadData(t *testing.T) {
+	ctx := context.Background()
+	src := cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize)
+	_, err := src.Post(ctx, []byte("hello"))
+	require.NoError(t, err)
+	p := Params{
+		Src:     wrongHash{src},
+		Dst:     newDst(),
+		Mapping: newMapping(),
+	}
+	_, err = Migrate(ctx, p, cadata.Span{})
+	require.ErrorIs(t, err, cadata.ErrBadData)
+}
+
+// wrongHash reports a different hash function than the one used to post its data.
+type wrongHash struct {
+	*cadata.MemStore
+}
+
+func (s wrongHash) Hash(x []byte) cadata.ID {
+	return sha256Hash(x)
+}
+
+func newDst() *cadata.MemStore {
+	return cadata.NewMem(sha256Hash, cadata.DefaultMaxSize)
+}
+
+func newMapping() Mapping {
+	return kv.NewMemStore[cadata.ID, cadata.ID](func(a, b cadata.ID) int {
+		return a.Compare(b)
+	})
+}
+
+func sha256Hash(x []byte) cadata.ID {
+	return sha256.Sum256(x)
+}
@@ -0,0 +1,284 @@
+// Package retention provides a cadata.Store which deletes data which has not been used recently.
+package retention
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.brendoncarroll.net/state/cadata"
+	"go.brendoncarroll.net/state/kv"
+)
+
+// DefaultBatchSize is the number of access times buffered before they are written, if Params.BatchSize is 0.
+const DefaultBatchSize = 256
+
+type Params struct {
+	// Inner is the Store holding the data.
+	Inner cadata.Store
+	// Times holds the last time each blob was Posted or retrieved with Get.
+	// If Times also implements kv.StoreTx, each batch is written in a single transaction.
+	Times kv.Store[cadata.ID, time.Time]
+	// Pins holds IDs which will never be swept.
+	Pins cadata.Set
+	// TTL is how long a blob can go unused before it is swept.
+	TTL time.Duration
+	// BatchSize is the number of access times to buffer in memory before writing them to Times.
+	BatchSize int
+	// Now returns the current time. time.Now is used if Now is nil.
+	Now func() time.Time
+}
+
+var _ cadata.Store = &Store{}
+
+// Store wraps a cadata.Store, and records when each blob was last used.
+// Blobs which have not been used for longer than the TTL are deleted by Sweep.
+type Store struct {
+	p Params
+
+	flushMu sync.Mutex
+	mu      sync.Mutex
+	// cond is signalled when IDs are removed from sweeping.
+	cond    *sync.Cond
+	pending map[cadata.ID]time.Time
+	// flushing holds the access times which Flush is writing to Times.
+	flushing map[cadata.ID]time.Time
+	// sweeping holds the IDs which Sweep may be deleting.
+	// Accesses and pins of these IDs wait until Sweep has decided.
+	sweeping map[cadata.ID]struct{}
+}
+
+func New(params Params) *Store {
+	if params.BatchSize <= 0 {
+		params.BatchSize = DefaultBatchSize
+	}
+	if params.Now == nil {
+		params.Now = time.Now
+	}
+	s := &Store{
+		p:        params,
+		pending:  make(map[cadata.ID]time.Time),
+		sweeping: make(map[cadata.ID]struct{}),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+func (s *Store) Post(ctx context.Context, data []byte) (cadata.ID, error) {
+	// the access is recorded first, so a concurrent Sweep cannot delete the blob after it is posted.
+	id := s.p.Inner.Hash(data)
+	full := s.record(id)
+	if _, err := s.p.Inner.Post(ctx, data); err != nil {
+		return cadata.ID{}, err
+	}
+	if full {
+		return id, s.Flush(ctx)
+	}
+	return id, nil
+}
+
+func (s *Store) Get(ctx context.Context, id cadata.ID, buf []byte) (int, error) {
+	n, err := s.p.Inner.Get(ctx, id, buf)
+	if err != nil {
+		return 0, err
+	}
+	return n, s.touch(ctx, id)
+}
+
+func (s *Store) Exists(ctx context.Context, id cadata.ID) (bool, error) {
+	return s.p.Inner.Exists(ctx, id)
+}
+
+func (s *Store) List(ctx context.Context, span cadata.Span, ids []cadata.ID) (int, error) {
+	return s.p.Inner.List(ctx, span, ids)
+}
+
+func (s *Store) Delete(ctx context.Context, id cadata.ID) error {
+	if err := s.p.Inner.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.pending, id)
+	s.mu.Unlock()
+	return s.p.Times.Delete(ctx, id)
+}
+
+func (s *Store) Hash(x []byte) cadata.ID {
+	return s.p.Inner.Hash(x)
+}
+
+func (s *Store) MaxSize() int {
+	return s.p.Inner.MaxSize()
+}
+
+// Pin exempts id from sweeping.
+// If Sweep is deciding whether to delete id, Pin waits until it is done.
+func (s *Store) Pin(ctx context.Context, id cadata.ID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.waitSweep(id)
+	return s.p.Pins.Add(ctx, id)
+}
+
+// Unpin allows id to be swept again.
+func (s *Store) Unpin(ctx context.Context, id cadata.ID) error {
+	return s.p.Pins.Delete(ctx, id)
+}
+
+// Flush writes all the buffered access times to Times.
+func (s *Store) Flush(ctx context.Context) error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+	s.mu.Lock()
+	batch := s.pending
+	if len(batch) == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	s.pending = make(map[cadata.ID]time.Time, len(batch))
+	s.flushing = batch
+	s.mu.Unlock()
+	err := s.putTimes(ctx, batch)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushing = nil
+	if err != nil {
+		// put the batch back, unless there has been a more recent access.
+		for id, t := range batch {
+			if _, exists := s.pending[id]; !exists {
+				s.pending[id] = t
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// Sweep deletes every unpinned blob which has not been used for longer than the TTL.
+// Blobs without a recorded access time, such as ones added to Inner directly, are given the current time.
+// Sweep returns the number of blobs deleted.
+func (s *Store) Sweep(ctx context.Context) (int, error) {
+	if err := s.Flush(ctx); err != nil {
+		return 0, err
+	}
+	now := s.p.Now()
+	var count int
+	err := cadata.ForEach(ctx, s.p.Inner, cadata.Span{}, func(id cadata.ID) error {
+		deleted, err := s.sweepOne(ctx, id, now)
+		if err != nil {
+			return err
+		}
+		if deleted {
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// sweepOne deletes id if it is not pinned, and has not been used since now minus the TTL.
+// id is added to sweeping while it is checked and deleted, so that it cannot be posted, used or pinned in the meantime.
+// No locks are held while the backing stores are called.
+func (s *Store) sweepOne(ctx context.Context, id cadata.ID, now time.Time) (bool, error) {
+	s.mu.Lock()
+	if s.isUsed(id) {
+		s.mu.Unlock()
+		return false, nil
+	}
+	s.sweeping[id] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.sweeping, id)
+		s.cond.Broadcast()
+		s.mu.Unlock()
+	}()
+
+	pinned, err := s.p.Pins.Exists(ctx, id)
+	if err != nil || pinned {
+		return false, err
+	}
+	last, err := kv.Get[cadata.ID, time.Time](ctx, s.p.Times, id)
+	if cadata.IsNotFound(err) {
+		return false, s.p.Times.Put(ctx, id, now)
+	} else if err != nil {
+		return false, err
+	}
+	if now.Sub(last) <= s.p.TTL {
+		return false, nil
+	}
+	if err := s.p.Inner.Delete(ctx, id); err != nil {
+		return false, err
+	}
+	return true, s.p.Times.Delete(ctx, id)
+}
+
+// Run calls Sweep every period until ctx is cancelled, or Sweep returns an error.
+func (s *Store) Run(ctx context.Context, period time.Duration) error {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if _, err := s.Sweep(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// touch records an access of id, and flushes the buffered access times if there are enough of them.
+func (s *Store) touch(ctx context.Context, id cadata.ID) error {
+	if s.record(id) {
+		return s.Flush(ctx)
+	}
+	return nil
+}
+
+// record buffers an access of id, and returns true if the buffer should be flushed.
+func (s *Store) record(id cadata.ID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.waitSweep(id)
+	s.pending[id] = s.p.Now()
+	return len(s.pending) >= s.p.BatchSize
+}
+
+// isUsed returns true if there is an access of id which has not been written to Times yet.
+// s.mu must be held.
+func (s *Store) isUsed(id cadata.ID) bool {
+	_, pending := s.pending[id]
+	_, flushing := s.flushing[id]
+	return pending || flushing
+}
+
+// waitSweep waits until Sweep is not deciding whether to delete id.
+// s.mu must be held.
+func (s *Store) waitSweep(id cadata.ID) {
+	for {
+		if _, exists := s.sweeping[id]; !exists {
+			return
+		}
+		s.cond.Wait()
+	}
+}
+
+func (s *Store) putTimes(ctx context.Context, batch map[cadata.ID]time.Time) error {
+	if tx, ok := s.p.Times.(kv.StoreTx[cadata.ID, time.Time]); ok {
+		return tx.Modify(ctx, func(tx kv.Store[cadata.ID, time.Time]) error {
+			return putAll(ctx, tx, batch)
+		})
+	}
+	return putAll(ctx, s.p.Times, batch)
+}
+
+func putAll(ctx context.Context, dst kv.Putter[cadata.ID, time.Time], batch map[cadata.ID]time.Time) error {
+	for id, t := range batch {
+		if err := dst.Put(ctx, id, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
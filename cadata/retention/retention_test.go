@@ -0,0 +1,265 @@
+package retention
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go.brendoncarroll.net/state/cadata"
+	"go.brendoncarroll.net/state/cadata/storetest"
+	"go.brendoncarroll.net/state/kv"
+)
+
+func TestStore(t *testing.T) {
+	storetest.TestStore(t, func(t testing.TB) cadata.Store {
+		s, _ := newTestStore(t, time.Hour)
+		return s
+	})
+}
+
+func TestSweep(t *testing.T) {
+	ctx := context.Background()
+	s, clock := newTestStore(t, time.Hour)
+	post := func(data string) cadata.ID {
+		id, err := s.Post(ctx, []byte(data))
+		require.NoError(t, err)
+		return id
+	}
+	exists := func(id cadata.ID) bool {
+		yes, err := s.Exists(ctx, id)
+		require.NoError(t, err)
+		return yes
+	}
+	sweep := func() int {
+		n, err := s.Sweep(ctx)
+		require.NoError(t, err)
+		return n
+	}
+
+	idle := post("idle")
+	used := post("used")
+	pinned := post("pinned")
+	require.NoError(t, s.Pin(ctx, pinned))
+
+	clock.Advance(30 * time.Minute)
+	require.Equal(t, 0, sweep())
+	_, err := cadata.GetBytes(ctx, s, used)
+	require.NoError(t, err)
+
+	clock.Advance(45 * time.Minute)
+	require.Equal(t, 1, sweep())
+	require.False(t, exists(idle))
+	require.True(t, exists(used))
+	require.True(t, exists(pinned))
+
+	clock.Advance(2 * time.Hour)
+	require.Equal(t, 1, sweep())
+	require.False(t, exists(used))
+	require.True(t, exists(pinned))
+
+	require.NoError(t, s.Unpin(ctx, pinned))
+	require.Equal(t, 1, sweep())
+	require.False(t, exists(pinned))
+}
+
+func TestSweepRepost(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	inner := &deleteHookStore{Store: cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize)}
+	s := New(Params{
+		Inner: inner,
+		Times: kv.NewMemStore[cadata.ID, time.Time](compareIDs),
+		Pins:  cadata.NewMemSet(),
+		TTL:   time.Hour,
+		Now:   clock.Now,
+	})
+	data := []byte("reposted")
+	id, err := s.Post(ctx, data)
+	require.NoError(t, err)
+	clock.Advance(2 * time.Hour)
+
+	// post the blob again while Sweep is deleting it.
+	done := make(chan error, 1)
+	inner.hook = func() {
+		go func() {
+			_, err := s.Post(ctx, data)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			done <- err
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	_, err = s.Sweep(ctx)
+	require.NoError(t, err)
+	require.NoError(t, <-done)
+	yes, err := s.Exists(ctx, id)
+	require.NoError(t, err)
+	require.True(t, yes)
+}
+
+func TestSweepPin(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	pins := &existsHookSet{Set: cadata.NewMemSet()}
+	s := New(Params{
+		Inner: cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize),
+		Times: kv.NewMemStore[cadata.ID, time.Time](compareIDs),
+		Pins:  pins,
+		TTL:   time.Hour,
+		Now:   clock.Now,
+	})
+	id, err := s.Post(ctx, []byte("pinned"))
+	require.NoError(t, err)
+	clock.Advance(2 * time.Hour)
+
+	// pin the blob after Sweep has checked the pins.
+	done := make(chan bool, 1)
+	pins.hook = func() {
+		go func() {
+			if err := s.Pin(ctx, id); err != nil {
+				t.Error(err)
+			}
+			yes, err := s.Exists(ctx, id)
+			if err != nil {
+				t.Error(err)
+			}
+			done <- yes
+		}()
+		select {
+		case yes := <-done:
+			done <- yes
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	_, err = s.Sweep(ctx)
+	require.NoError(t, err)
+	// a blob which exists once it is pinned must never be swept.
+	if <-done {
+		yes, err := s.Exists(ctx, id)
+		require.NoError(t, err)
+		require.True(t, yes)
+	}
+}
+
+func TestSweepDoesNotBlock(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	inner := &deleteHookStore{Store: cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize)}
+	s := New(Params{
+		Inner: inner,
+		Times: kv.NewMemStore[cadata.ID, time.Time](compareIDs),
+		Pins:  cadata.NewMemSet(),
+		TTL:   time.Hour,
+		Now:   clock.Now,
+	})
+	_, err := s.Post(ctx, []byte("idle"))
+	require.NoError(t, err)
+	clock.Advance(2 * time.Hour)
+	used, err := s.Post(ctx, []byte("used"))
+	require.NoError(t, err)
+
+	// other blobs can be used while a blob is being deleted.
+	inner.hook = func() {
+		done := make(chan error, 1)
+		go func() {
+			_, err := cadata.GetBytes(ctx, s, used)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Error(err)
+			}
+		case <-time.After(time.Second):
+			t.Error("Get blocked by Sweep")
+		}
+	}
+	n, err := s.Sweep(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+}
+
+func TestBatching(t *testing.T) {
+	ctx := context.Background()
+	times := kv.NewMemStore[cadata.ID, time.Time](compareIDs)
+	s := New(Params{
+		Inner:     cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize),
+		Times:     times,
+		Pins:      cadata.NewMemSet(),
+		TTL:       time.Hour,
+		BatchSize: 10,
+	})
+	for i := 0; i < 9; i++ {
+		_, err := s.Post(ctx, []byte{byte(i)})
+		require.NoError(t, err)
+	}
+	require.Equal(t, 0, times.Len())
+	_, err := s.Post(ctx, []byte{9})
+	require.NoError(t, err)
+	require.Equal(t, 10, times.Len())
+}
+
+func newTestStore(t testing.TB, ttl time.Duration) (*Store, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	s := New(Params{
+		Inner: cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize),
+		Times: kv.NewMemStore[cadata.ID, time.Time](compareIDs),
+		Pins:  cadata.NewMemSet(),
+		TTL:   ttl,
+		Now:   clock.Now,
+	})
+	return s, clock
+}
+
+func compareIDs(a, b cadata.ID) int {
+	return a.Compare(b)
+}
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// deleteHookStore calls hook, if it is set, before each Delete.
+type deleteHookStore struct {
+	cadata.Store
+	hook func()
+}
+
+func (s *deleteHookStore) Delete(ctx context.Context, id cadata.ID) error {
+	if s.hook != nil {
+		s.hook()
+	}
+	return s.Store.Delete(ctx, id)
+}
+
+// existsHookSet calls hook, if it is set, after each Exists.
+type existsHookSet struct {
+	cadata.Set
+	hook func()
+}
+
+func (s *existsHookSet) Exists(ctx context.Context, id cadata.ID) (bool, error) {
+	yes, err := s.Set.Exists(ctx, id)
+	if s.hook != nil {
+		s.hook()
+	}
+	return yes, err
+}
@@ -0,0 +1,46 @@
+package cadata
+
+import (
+	"context"
+
+	"go.brendoncarroll.net/state/kv"
+)
+
+var _ Set = &MemSet{}
+
+// MemSet is a Set which keeps IDs in memory.
+type MemSet struct {
+	s *kv.MemStore[ID, struct{}]
+}
+
+func NewMemSet() *MemSet {
+	return &MemSet{
+		s: kv.NewMemStore[ID, struct{}](func(a, b ID) int {
+			return a.Compare(b)
+		}),
+	}
+}
+
+func (s *MemSet) Add(ctx context.Context, id ID) error {
+	return s.s.Put(ctx, id, struct{}{})
+}
+
+func (s *MemSet) Delete(ctx context.Context, id ID) error {
+	return s.s.Delete(ctx, id)
+}
+
+func (s *MemSet) DeleteSpan(ctx context.Context, span Span) (int, error) {
+	return s.s.DeleteSpan(ctx, span)
+}
+
+func (s *MemSet) Exists(ctx context.Context, id ID) (bool, error) {
+	return s.s.Exists(ctx, id)
+}
+
+func (s *MemSet) List(ctx context.Context, span Span, ids []ID) (int, error) {
+	return s.s.List(ctx, span, ids)
+}
+
+func (s *MemSet) Len() int {
+	return s.s.Len()
+}
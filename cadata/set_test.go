@@ -0,0 +1,47 @@
+package cadata_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.brendoncarroll.net/state/cadata"
+	"go.brendoncarroll.net/state/cadata/storetest"
+)
+
+func TestMemSet(t *testing.T) {
+	storetest.TestSet(t, func(t testing.TB) cadata.Set {
+		return cadata.NewMemSet()
+	})
+}
+
+func TestVirtual(t *testing.T) {
+	storetest.TestStore(t, func(t testing.TB) cadata.Store {
+		backing := cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize)
+		return cadata.NewVirtual(backing, cadata.NewMemSet())
+	})
+	t.Run("Add", func(t *testing.T) {
+		ctx := context.Background()
+		backing := cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize)
+		v1 := cadata.NewVirtual(backing, cadata.NewMemSet())
+		v2 := cadata.NewVirtual(backing, cadata.NewMemSet())
+
+		id, err := v1.Post(ctx, []byte("shared data"))
+		require.NoError(t, err)
+		exists, err := v2.Exists(ctx, id)
+		require.NoError(t, err)
+		require.False(t, exists)
+
+		// Copy should succeed using Add, without reading from src.
+		src := cadata.NewVoid(cadata.DefaultHash, cadata.DefaultMaxSize)
+		require.NoError(t, cadata.Copy(ctx, v2, src, id))
+		data, err := cadata.GetBytes(ctx, v2, id)
+		require.NoError(t, err)
+		require.Equal(t, "shared data", string(data))
+
+		missing := cadata.DefaultHash([]byte("missing"))
+		require.ErrorIs(t, v2.Add(ctx, missing), cadata.ErrNotFound{Key: missing})
+		require.Equal(t, 1, backing.Len())
+	})
+}
@@ -0,0 +1,66 @@
+package storetest
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.brendoncarroll.net/state/cadata"
+)
+
+// TestSet runs the conformance suite against a Set.
+func TestSet(t *testing.T, newSet func(t testing.TB) cadata.Set) {
+	ctx := context.Background()
+	t.Run("AddExists", func(t *testing.T) {
+		s := newSet(t)
+		id := cadata.DefaultHash([]byte("test"))
+		require.False(t, setExists(t, s, id))
+		require.NoError(t, s.Add(ctx, id))
+		require.True(t, setExists(t, s, id))
+		// Add is idempotent
+		require.NoError(t, s.Add(ctx, id))
+		require.Equal(t, []ID{id}, list(t, s, Span{}))
+	})
+	t.Run("Delete", func(t *testing.T) {
+		s := newSet(t)
+		ids := addN(t, s, 10)
+		require.NoError(t, s.Delete(ctx, ids[3]))
+		require.False(t, setExists(t, s, ids[3]))
+		expected := append(append([]ID{}, ids[:3]...), ids[4:]...)
+		require.Equal(t, expected, list(t, s, Span{}))
+		// deleting an absent ID is not an error
+		require.NoError(t, s.Delete(ctx, ids[3]))
+	})
+	t.Run("List", func(t *testing.T) {
+		s := newSet(t)
+		ids := addN(t, s, 100)
+		require.Equal(t, ids, list(t, s, Span{}))
+		span := Span{}.WithLowerExcl(ids[10]).WithUpperIncl(ids[20])
+		require.Equal(t, ids[11:21], list(t, s, span))
+	})
+}
+
+// addN adds n distinct IDs to s, and returns them in ascending order.
+func addN(t testing.TB, s cadata.Set, n int) []ID {
+	ctx := context.Background()
+	ids := make([]ID, n)
+	buf := make([]byte, 32)
+	for i := range ids {
+		readRandom(i, buf)
+		ids[i] = cadata.DefaultHash(buf)
+		require.NoError(t, s.Add(ctx, ids[i]))
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Compare(ids[j]) < 0
+	})
+	return ids
+}
+
+func setExists(t testing.TB, s cadata.Set, id ID) bool {
+	ctx := context.Background()
+	yes, err := s.Exists(ctx, id)
+	require.NoError(t, err)
+	return yes
+}
@@ -2,7 +2,12 @@ package storetest
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	mrand "math/rand"
+	"sort"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -13,10 +18,38 @@ import (
 type (
 	Store = cadata.Store
 	ID    = cadata.ID
+	Span  = cadata.Span
 )
 
+// Caps describes the optional behaviors of a Store.
+// Tests which depend on a behavior that the Store does not have are skipped.
+type Caps struct {
+	// Retains is true if data Posted to the Store can be read back with Get, Exists, and List.
+	// Stores which discard everything, like cadata.Void, should set this to false.
+	Retains bool
+}
+
+// FullCaps are the Caps of a Store which implements all the optional behaviors.
+var FullCaps = Caps{
+	Retains: true,
+}
+
+// TestStore runs the conformance suite against a Store with FullCaps.
 func TestStore(t *testing.T, newStore func(t testing.TB) Store) {
+	TestStoreCaps(t, FullCaps, newStore)
+}
+
+// TestStoreCaps runs the conformance suite against a Store with the given Caps.
+func TestStoreCaps(t *testing.T, caps Caps, newStore func(t testing.TB) Store) {
+	ctx := context.Background()
+	requireRetains := func(t *testing.T) {
+		if !caps.Retains {
+			t.Skip("store does not retain data")
+		}
+	}
+
 	t.Run("ExistsPostGet", func(t *testing.T) {
+		requireRetains(t)
 		s := newStore(t)
 		testData := make([]byte, 1024)
 		id1 := s.Hash(testData)
@@ -27,58 +60,369 @@ func TestStore(t *testing.T, newStore func(t testing.TB) Store) {
 		dataOut := get(t, s, id2)
 		require.Equal(t, testData, dataOut)
 	})
-	t.Run("List", func(t *testing.T) {
-		s := newStore(t)
-		buf := make([]byte, 1024)
-		for i := 0; i < 100; i++ {
-			readRandom(i, buf)
-			post(t, s, buf)
-		}
-		ids := list(t, s)
-		require.Len(t, ids, 100)
-	})
 	t.Run("RecallOne", func(t *testing.T) {
+		requireRetains(t)
 		s := newStore(t)
 		testData := []byte("test string goes here")
 		id := post(t, s, testData)
 		actual := get(t, s, id)
 		require.Equal(t, testData, actual)
 	})
+	t.Run("PostEmpty", func(t *testing.T) {
+		requireRetains(t)
+		s := newStore(t)
+		id := post(t, s, nil)
+		require.Equal(t, s.Hash(nil), id)
+		require.True(t, exists(t, s, id))
+		require.Len(t, get(t, s, id), 0)
+	})
+	t.Run("PostIdempotent", func(t *testing.T) {
+		requireRetains(t)
+		s := newStore(t)
+		testData := []byte("posted more than once")
+		id1 := post(t, s, testData)
+		id2 := post(t, s, testData)
+		require.Equal(t, id1, id2)
+		require.Equal(t, []ID{id1}, list(t, s, Span{}))
+	})
+	t.Run("PostHash", func(t *testing.T) {
+		s := newStore(t)
+		buf := make([]byte, 100)
+		for i := 0; i < 10; i++ {
+			readRandom(i, buf)
+			require.Equal(t, s.Hash(buf), post(t, s, buf))
+		}
+	})
+	t.Run("GetNotFound", func(t *testing.T) {
+		s := newStore(t)
+		id := s.Hash([]byte("never posted"))
+		buf := make([]byte, s.MaxSize())
+		_, err := s.Get(ctx, id, buf)
+		require.True(t, cadata.IsNotFound(err), "wrong error: %v", err)
+		var errNotFound cadata.ErrNotFound
+		require.True(t, errors.As(err, &errNotFound))
+		require.Equal(t, id, errNotFound.Key)
+	})
+	t.Run("GetShortBuffer", func(t *testing.T) {
+		requireRetains(t)
+		s := newStore(t)
+		testData := make([]byte, 100)
+		readRandom(0, testData)
+		id := post(t, s, testData)
+
+		_, err := s.Get(ctx, id, make([]byte, len(testData)-1))
+		require.ErrorIs(t, err, io.ErrShortBuffer)
+		buf := make([]byte, len(testData))
+		n, err := s.Get(ctx, id, buf)
+		require.NoError(t, err)
+		require.Equal(t, testData, buf[:n])
+	})
+	t.Run("ExistsAbsent", func(t *testing.T) {
+		s := newStore(t)
+		require.False(t, exists(t, s, ID{}))
+		require.False(t, exists(t, s, s.Hash([]byte("never posted"))))
+	})
+	t.Run("Delete", func(t *testing.T) {
+		requireRetains(t)
+		s := newStore(t)
+		ids := postN(t, s, 10)
+		target := ids[3]
+		require.NoError(t, s.Delete(ctx, target))
+		require.False(t, exists(t, s, target))
+		_, err := s.Get(ctx, target, make([]byte, s.MaxSize()))
+		require.True(t, cadata.IsNotFound(err), "wrong error: %v", err)
+		expected := append(append([]ID{}, ids[:3]...), ids[4:]...)
+		require.Equal(t, expected, list(t, s, Span{}))
+	})
+	t.Run("DeleteSpan", func(t *testing.T) {
+		requireRetains(t)
+		s := newStore(t)
+		ids := postRandom(t, s, 200, 16)
+		span := Span{}.WithLowerExcl(ids[20]).WithUpperIncl(ids[180])
+		n, err := cadata.DeleteSpan(ctx, s, span)
+		require.NoError(t, err)
+		require.Equal(t, 160, n)
+		expected := append(append([]ID{}, ids[:21]...), ids[181:]...)
+		require.Equal(t, expected, list(t, s, Span{}))
+		require.False(t, exists(t, s, ids[100]))
+
+		n, err = cadata.DeleteSpan(ctx, s, span)
+		require.NoError(t, err)
+		require.Equal(t, 0, n)
+		n, err = cadata.DeleteSpan(ctx, s, Span{})
+		require.NoError(t, err)
+		require.Equal(t, 40, n)
+		require.Len(t, list(t, s, Span{}), 0)
+	})
+	t.Run("DeleteAbsent", func(t *testing.T) {
+		s := newStore(t)
+		require.NoError(t, s.Delete(ctx, s.Hash([]byte("never posted"))))
+	})
+	t.Run("List", func(t *testing.T) {
+		requireRetains(t)
+		s := newStore(t)
+		ids := postN(t, s, 100)
+		actual := list(t, s, Span{})
+		require.Len(t, actual, 100)
+		require.Equal(t, ids, actual)
+	})
+	t.Run("ListEmpty", func(t *testing.T) {
+		s := newStore(t)
+		if caps.Retains {
+			require.Len(t, list(t, s, Span{}), 0)
+			return
+		}
+		postN(t, s, 10)
+		require.Len(t, list(t, s, Span{}), 0)
+	})
+	t.Run("ListSpan", func(t *testing.T) {
+		requireRetains(t)
+		s := newStore(t)
+		ids := postN(t, s, 20)
+		tcs := []struct {
+			Name     string
+			Span     Span
+			Expected []ID
+		}{
+			{"Total", Span{}, ids},
+			{"Point", Span{}.WithLowerIncl(ids[3]).WithUpperIncl(ids[3]), ids[3:4]},
+			{"InclExcl", Span{}.WithLowerIncl(ids[5]).WithUpperExcl(ids[10]), ids[5:10]},
+			{"InclIncl", Span{}.WithLowerIncl(ids[5]).WithUpperIncl(ids[10]), ids[5:11]},
+			{"ExclExcl", Span{}.WithLowerExcl(ids[5]).WithUpperExcl(ids[10]), ids[6:10]},
+			{"ExclIncl", Span{}.WithLowerExcl(ids[5]).WithUpperIncl(ids[10]), ids[6:11]},
+			{"LowerOnly", Span{}.WithLowerIncl(ids[15]), ids[15:]},
+			{"UpperOnly", Span{}.WithUpperExcl(ids[5]), ids[:5]},
+			{"Adjacent", Span{}.WithLowerExcl(ids[3]).WithUpperExcl(ids[4]), nil},
+			{"LowerSuccessor", Span{}.WithLowerIncl(ids[5].Successor()), ids[6:]},
+			{"UpperSuccessor", Span{}.WithUpperExcl(ids[5].Successor()), ids[:6]},
+			{"ZeroLower", Span{}.WithLowerIncl(ID{}), ids},
+			{"ZeroUpper", Span{}.WithUpperExcl(ID{}), nil},
+		}
+		for _, tc := range tcs {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				actual := list(t, s, tc.Span)
+				if len(tc.Expected) == 0 {
+					require.Len(t, actual, 0)
+				} else {
+					require.Equal(t, tc.Expected, actual)
+				}
+			})
+		}
+	})
+	t.Run("ListDesc", func(t *testing.T) {
+		requireRetains(t)
+		s := newStore(t)
+		ids := postN(t, s, 20)
+		tcs := []struct {
+			Name     string
+			Span     Span
+			Expected []ID
+		}{
+			{"Total", Span{}.Desc(), reverseIDs(ids)},
+			{"InclExcl", Span{}.WithLowerIncl(ids[5]).WithUpperExcl(ids[10]).Desc(), reverseIDs(ids[5:10])},
+			{"ExclIncl", Span{}.WithLowerExcl(ids[5]).WithUpperIncl(ids[10]).Desc(), reverseIDs(ids[6:11])},
+			{"UpperOnly", Span{}.WithUpperIncl(ids[5]).Desc(), reverseIDs(ids[:6])},
+			{"ZeroUpper", Span{}.WithUpperExcl(ID{}).Desc(), nil},
+		}
+		for _, tc := range tcs {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				actual := list(t, s, tc.Span)
+				if len(tc.Expected) == 0 {
+					require.Len(t, actual, 0)
+				} else {
+					require.Equal(t, tc.Expected, actual)
+				}
+			})
+		}
+	})
+	t.Run("ListPagination", func(t *testing.T) {
+		requireRetains(t)
+		s := newStore(t)
+		ids := postN(t, s, 50)
+		for _, desc := range []bool{false, true} {
+			for _, bufSize := range []int{1, 2, 3, 7, 50, 100} {
+				var actual []ID
+				span := Span{}
+				if desc {
+					span = span.Desc()
+				}
+				buf := make([]ID, bufSize)
+				for {
+					n, err := s.List(ctx, span, buf)
+					require.NoError(t, err)
+					require.LessOrEqual(t, n, bufSize)
+					if n == 0 {
+						break
+					}
+					actual = append(actual, buf[:n]...)
+					span = span.After(buf[n-1])
+				}
+				expected := ids
+				if desc {
+					expected = reverseIDs(ids)
+				}
+				require.Equal(t, expected, actual, "bufSize=%d desc=%v", bufSize, desc)
+			}
+		}
+	})
 	t.Run("MaxSize", func(t *testing.T) {
 		s := newStore(t)
 		data := make([]byte, s.MaxSize())
 		post(t, s, data)
 		dataTooBig := make([]byte, s.MaxSize()+1)
-		ctx := context.Background()
 		_, err := s.Post(ctx, dataTooBig)
 		require.ErrorIs(t, err, cadata.ErrTooLarge)
 	})
+	t.Run("ConcurrentPost", func(t *testing.T) {
+		s := newStore(t)
+		const (
+			numWorkers = 8
+			perWorker  = 16
+		)
+		var wg sync.WaitGroup
+		errs := make(chan error, numWorkers)
+		for i := 0; i < numWorkers; i++ {
+			i := i
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				buf := make([]byte, 256)
+				for j := 0; j < perWorker; j++ {
+					// half of the workers post the same data as their neighbor.
+					readRandom((i/2)*perWorker+j, buf)
+					id, err := s.Post(ctx, buf)
+					if err != nil {
+						errs <- err
+						return
+					}
+					if id != s.Hash(buf) {
+						errs <- errors.New("wrong id from Post")
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			require.NoError(t, err)
+		}
+		if caps.Retains {
+			require.Len(t, list(t, s, Span{}), numWorkers/2*perWorker)
+		}
+	})
 }
 
-func get(t *testing.T, s Store, id ID) []byte {
+// BenchmarkStore runs benchmarks against a Store.
+func BenchmarkStore(b *testing.B, newStore func(t testing.TB) Store) {
+	ctx := context.Background()
+	for _, size := range []int{1 << 10, 1 << 16} {
+		size := size
+		b.Run(sizeName("Post", size), func(b *testing.B) {
+			s := newStore(b)
+			buf := make([]byte, size)
+			rng := mrand.New(mrand.NewSource(0))
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				rng.Read(buf)
+				b.StartTimer()
+				_, err := s.Post(ctx, buf)
+				require.NoError(b, err)
+			}
+		})
+		b.Run(sizeName("Get", size), func(b *testing.B) {
+			s := newStore(b)
+			ids := postRandom(b, s, 100, size)
+			buf := make([]byte, s.MaxSize())
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := s.Get(ctx, ids[i%len(ids)], buf)
+				if err != nil && !cadata.IsNotFound(err) {
+					require.NoError(b, err)
+				}
+			}
+		})
+	}
+	b.Run("Exists", func(b *testing.B) {
+		s := newStore(b)
+		ids := postRandom(b, s, 100, 1<<10)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, err := s.Exists(ctx, ids[i%len(ids)])
+			require.NoError(b, err)
+		}
+	})
+	b.Run("List", func(b *testing.B) {
+		s := newStore(b)
+		postRandom(b, s, 1000, 64)
+		buf := make([]ID, 64)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, err := s.List(ctx, Span{}, buf)
+			require.NoError(b, err)
+		}
+	})
+}
+
+func sizeName(op string, size int) string {
+	if size >= 1<<10 {
+		return fmt.Sprintf("%s-%dKB", op, size>>10)
+	}
+	return fmt.Sprintf("%s-%dB", op, size)
+}
+
+func get(t testing.TB, s Store, id ID) []byte {
 	ctx := context.Background()
 	data, err := cadata.GetBytes(ctx, s, id)
 	require.NoError(t, err)
 	return data
 }
 
-func post(t *testing.T, s Store, data []byte) ID {
+func post(t testing.TB, s Store, data []byte) ID {
 	ctx := context.Background()
 	id, err := s.Post(ctx, data)
 	require.NoError(t, err)
 	return id
 }
 
-func exists(t *testing.T, s Store, id ID) bool {
+// postN posts n distinct blobs and returns their IDs in ascending order.
+func postN(t testing.TB, s Store, n int) []ID {
+	return postRandom(t, s, n, 1024)
+}
+
+// postRandom posts n random blobs of the given size and returns their IDs in ascending order.
+func postRandom(t testing.TB, s Store, n, size int) []ID {
+	buf := make([]byte, size)
+	ids := make([]ID, n)
+	for i := range ids {
+		readRandom(i, buf)
+		ids[i] = post(t, s, buf)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Compare(ids[j]) < 0
+	})
+	return ids
+}
+
+func exists(t testing.TB, s Store, id ID) bool {
 	ctx := context.Background()
 	yes, err := s.Exists(ctx, id)
 	require.NoError(t, err)
 	return yes
 }
 
-func list(t *testing.T, s Store) (ret []cadata.ID) {
+func list(t testing.TB, s cadata.Lister, span Span) (ret []cadata.ID) {
 	ctx := context.Background()
-	err := cadata.ForEach(ctx, s, cadata.Span{}, func(id ID) error {
+	err := cadata.ForEach(ctx, s, span, func(id ID) error {
 		ret = append(ret, id)
 		return nil
 	})
@@ -86,6 +430,14 @@ func list(t *testing.T, s Store) (ret []cadata.ID) {
 	return ret
 }
 
+func reverseIDs(ids []ID) []ID {
+	ret := make([]ID, len(ids))
+	for i := range ids {
+		ret[len(ids)-1-i] = ids[i]
+	}
+	return ret
+}
+
 func readRandom(i int, buf []byte) {
 	rng := mrand.New(mrand.NewSource(int64(i)))
 	rng.Read(buf)
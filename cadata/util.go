@@ -3,10 +3,8 @@ package cadata
 import (
 	"context"
 	"errors"
-	"runtime"
 
 	"go.brendoncarroll.net/state/kv"
-	"golang.org/x/sync/errgroup"
 )
 
 // ForEachSpan calls fn with every ID in the range
@@ -49,39 +47,24 @@ func CopyAll(ctx context.Context, dst Poster, src GetLister) error {
 }
 
 func CopyAllBasic(ctx context.Context, dst Poster, src GetLister) error {
-	numWorkers := runtime.GOMAXPROCS(0)
-	ch := make(chan ID)
-	eg, ctx := errgroup.WithContext(ctx)
-	eg.Go(func() error {
-		return ForEach(ctx, src, Span{}, func(id ID) error {
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case ch <- id:
-			}
-			return nil
-		})
+	return ParallelForEach(ctx, src, Span{}, ForEachParams{}, func(id ID) error {
+		return Copy(ctx, dst, src, id)
 	})
-	for i := 0; i < numWorkers; i++ {
-		eg.Go(func() error {
-			for id := range ch {
-				if err := Copy(ctx, dst, src, id); err != nil {
-					return err
-				}
-			}
-			return nil
-		})
-	}
-	return eg.Wait()
 }
 
 // DeleteAll deletes all the data in s
-func DeleteAll(ctx context.Context, s Store) error {
-	return ForEach(ctx, s, Span{}, func(id ID) error {
+func DeleteAll(ctx context.Context, s ListDeleter) error {
+	return ParallelForEach(ctx, s, Span{}, ForEachParams{}, func(id ID) error {
 		return s.Delete(ctx, id)
 	})
 }
 
+// DeleteSpan deletes all the IDs in span from s, and returns the number deleted.
+// If s is a SpanDeleter its DeleteSpan method is used, otherwise the IDs are deleted one at a time.
+func DeleteSpan(ctx context.Context, s ListDeleter, span Span) (int, error) {
+	return kv.DeleteSpan[ID](ctx, s, span)
+}
+
 func GetF(ctx context.Context, s Getter, id ID, fn func([]byte) error) error {
 	if getF, ok := s.(interface {
 		GetF(context.Context, ID, func([]byte) error) error
@@ -0,0 +1,89 @@
+package cadata
+
+import (
+	"context"
+)
+
+var (
+	_ Store = &VirtualStore{}
+	_ Adder = &VirtualStore{}
+)
+
+// VirtualStore presents the subset of a shared Store identified by a Set.
+// Many VirtualStores can share the same backing Store, each with their own Set,
+// so that data is only stored once, but each tenant can only see the data they have added.
+type VirtualStore struct {
+	store Store
+	set   Set
+}
+
+// NewVirtual returns a VirtualStore which stores data in store, and which IDs are visible in set.
+func NewVirtual(store Store, set Set) *VirtualStore {
+	return &VirtualStore{
+		store: store,
+		set:   set,
+	}
+}
+
+func (s *VirtualStore) Post(ctx context.Context, data []byte) (ID, error) {
+	id, err := s.store.Post(ctx, data)
+	if err != nil {
+		return ID{}, err
+	}
+	if err := s.set.Add(ctx, id); err != nil {
+		return ID{}, err
+	}
+	return id, nil
+}
+
+// Add makes id visible in the VirtualStore, if the backing Store already has it.
+// If the backing Store does not have the data, Add returns ErrNotFound.
+func (s *VirtualStore) Add(ctx context.Context, id ID) error {
+	exists, err := s.store.Exists(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound{Key: id}
+	}
+	return s.set.Add(ctx, id)
+}
+
+func (s *VirtualStore) Get(ctx context.Context, id ID, buf []byte) (int, error) {
+	exists, err := s.set.Exists(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, ErrNotFound{Key: id}
+	}
+	return s.store.Get(ctx, id, buf)
+}
+
+func (s *VirtualStore) Exists(ctx context.Context, id ID) (bool, error) {
+	return s.set.Exists(ctx, id)
+}
+
+func (s *VirtualStore) List(ctx context.Context, span Span, ids []ID) (int, error) {
+	return s.set.List(ctx, span, ids)
+}
+
+// Delete removes id from the VirtualStore.
+// The data is not removed from the backing Store, since it may be visible to other VirtualStores.
+func (s *VirtualStore) Delete(ctx context.Context, id ID) error {
+	return s.set.Delete(ctx, id)
+}
+
+// DeleteSpan removes the IDs in span from the VirtualStore.
+// Like Delete, the data is not removed from the backing Store.
+func (s *VirtualStore) DeleteSpan(ctx context.Context, span Span) (int, error) {
+	return DeleteSpan(ctx, s.set, span)
+}
+
+func (s *VirtualStore) Hash(x []byte) ID {
+	return s.store.Hash(x)
+}
+
+func (s *VirtualStore) MaxSize() int {
+	return s.store.MaxSize()
+}
@@ -0,0 +1,174 @@
+package cadata
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// DefaultWatchBufferSize is the number of Events buffered for each call to Watch.
+const DefaultWatchBufferSize = 64
+
+// ErrEventsDropped is returned from Watch when Events were produced faster than they were consumed,
+// and the buffer filled up.
+// Calling Watch again replays the IDs which are still in the store, which recovers any dropped Posts,
+// but dropped Deletes are lost.
+// Consumers which track deletions, such as indexes, should compare a full List of the store with their own state
+// to find the IDs which were deleted.
+var ErrEventsDropped = errors.New("cadata: watch buffer full, events were dropped")
+
+// Op is the kind of change described by an Event
+type Op uint8
+
+const (
+	OpPost = Op(iota + 1)
+	OpDelete
+)
+
+func (op Op) String() string {
+	switch op {
+	case OpPost:
+		return "POST"
+	case OpDelete:
+		return "DELETE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Event describes a change to a Store
+type Event struct {
+	Op Op
+	ID ID
+}
+
+// Watcher defines the Watch method
+type Watcher interface {
+	// Watch first calls fn with an OpPost Event for every ID in span which is already in the store, in ascending order.
+	// Then it calls fn with an Event for every change to an ID in span, until ctx is cancelled or fn returns an error.
+	// Events are delivered at least once; an ID posted during the replay may be seen twice.
+	// If Events are dropped because fn is too slow, Watch returns ErrEventsDropped.
+	Watch(ctx context.Context, span Span, fn func(Event) error) error
+}
+
+var (
+	_ Store   = &WatchedStore{}
+	_ Watcher = &WatchedStore{}
+)
+
+// WatchedStore wraps a Store, and emits Events for every Post and Delete made through it.
+// Changes made to the inner Store directly will not produce Events.
+type WatchedStore struct {
+	Store
+	hub watchHub
+}
+
+// NewWatched returns a WatchedStore wrapping inner, which buffers up to bufSize Events for each watcher.
+func NewWatched(inner Store, bufSize int) *WatchedStore {
+	return &WatchedStore{
+		Store: inner,
+		hub:   newWatchHub(bufSize),
+	}
+}
+
+func (s *WatchedStore) Post(ctx context.Context, data []byte) (ID, error) {
+	id, err := s.Store.Post(ctx, data)
+	if err != nil {
+		return ID{}, err
+	}
+	s.hub.publish(Event{Op: OpPost, ID: id})
+	return id, nil
+}
+
+func (s *WatchedStore) Delete(ctx context.Context, id ID) error {
+	if err := s.Store.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.hub.publish(Event{Op: OpDelete, ID: id})
+	return nil
+}
+
+func (s *WatchedStore) Watch(ctx context.Context, span Span, fn func(Event) error) error {
+	return s.hub.watch(ctx, s.Store, span, fn)
+}
+
+// watchHub delivers Events to watchers through bounded buffers.
+type watchHub struct {
+	bufSize int
+
+	mu   sync.Mutex
+	subs map[*watchSub]struct{}
+}
+
+type watchSub struct {
+	span     Span
+	ch       chan Event
+	overflow chan struct{}
+}
+
+func newWatchHub(bufSize int) watchHub {
+	if bufSize <= 0 {
+		bufSize = DefaultWatchBufferSize
+	}
+	return watchHub{
+		bufSize: bufSize,
+		subs:    make(map[*watchSub]struct{}),
+	}
+}
+
+// publish sends ev to every watcher whose span contains ev.ID, without blocking.
+// Watchers which cannot accept the Event are removed, and will return ErrEventsDropped.
+func (h *watchHub) publish(ev Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		if !sub.span.Contains(ev.ID, compareIDs) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			close(sub.overflow)
+			delete(h.subs, sub)
+		}
+	}
+}
+
+func (h *watchHub) watch(ctx context.Context, x Lister, span Span, fn func(Event) error) error {
+	sub := &watchSub{
+		span:     span,
+		ch:       make(chan Event, h.bufSize),
+		overflow: make(chan struct{}),
+	}
+	// subscribe before replaying, so no changes are missed between the two.
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.subs, sub)
+		h.mu.Unlock()
+	}()
+
+	if err := ForEach(ctx, x, span, func(id ID) error {
+		return fn(Event{Op: OpPost, ID: id})
+	}); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev := <-sub.ch:
+			if err := fn(ev); err != nil {
+				return err
+			}
+		case <-sub.overflow:
+			return ErrEventsDropped
+		}
+	}
+}
+
+func compareIDs(a, b ID) int {
+	return a.Compare(b)
+}
@@ -0,0 +1,125 @@
+package cadata_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.brendoncarroll.net/state/cadata"
+	"go.brendoncarroll.net/state/cadata/storetest"
+)
+
+func TestWatch(t *testing.T) {
+	t.Run("MemStore", func(t *testing.T) {
+		testWatcher(t, func(t testing.TB) watchStore {
+			return cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize)
+		})
+	})
+	t.Run("Watched", func(t *testing.T) {
+		storetest.TestStore(t, func(t testing.TB) cadata.Store {
+			return cadata.NewWatched(cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize), 0)
+		})
+		testWatcher(t, func(t testing.TB) watchStore {
+			return cadata.NewWatched(cadata.NewMem(cadata.DefaultHash, cadata.DefaultMaxSize), 0)
+		})
+	})
+}
+
+type watchStore interface {
+	cadata.Store
+	cadata.Watcher
+}
+
+func testWatcher(t *testing.T, newStore func(t testing.TB) watchStore) {
+	ctx := context.Background()
+	post := func(t testing.TB, s cadata.Poster, x byte) cadata.ID {
+		id, err := s.Post(ctx, []byte{x})
+		require.NoError(t, err)
+		return id
+	}
+	t.Run("ReplayThenLive", func(t *testing.T) {
+		s := newStore(t)
+		before := post(t, s, 0)
+		events := make(chan cadata.Event)
+		ctx, cf := context.WithCancel(ctx)
+		defer cf()
+		done := make(chan error, 1)
+		go func() {
+			done <- s.Watch(ctx, cadata.Span{}, func(ev cadata.Event) error {
+				events <- ev
+				return nil
+			})
+		}()
+		require.Equal(t, cadata.Event{Op: cadata.OpPost, ID: before}, <-events)
+
+		after := post(t, s, 1)
+		require.Equal(t, cadata.Event{Op: cadata.OpPost, ID: after}, <-events)
+		require.NoError(t, s.Delete(ctx, before))
+		require.Equal(t, cadata.Event{Op: cadata.OpDelete, ID: before}, <-events)
+
+		cf()
+		require.ErrorIs(t, <-done, context.Canceled)
+	})
+	t.Run("Span", func(t *testing.T) {
+		s := newStore(t)
+		var ids []cadata.ID
+		for i := 0; i < 10; i++ {
+			ids = append(ids, cadata.DefaultHash([]byte{byte(i)}))
+		}
+		sortIDs(ids)
+		span := cadata.Span{}.WithLowerIncl(ids[5])
+		events := make(chan cadata.Event, len(ids))
+		ctx, cf := context.WithCancel(ctx)
+		defer cf()
+		go s.Watch(ctx, span, func(ev cadata.Event) error {
+			events <- ev
+			return nil
+		})
+		for i := 0; i < 10; i++ {
+			post(t, s, byte(i))
+		}
+		// IDs may be seen twice, if they are posted during the replay.
+		seen := map[cadata.ID]struct{}{}
+		for len(seen) < 5 {
+			ev := <-events
+			require.True(t, span.Contains(ev.ID, func(a, b cadata.ID) int { return a.Compare(b) }))
+			seen[ev.ID] = struct{}{}
+		}
+		for _, id := range ids[5:] {
+			require.Contains(t, seen, id)
+		}
+	})
+	t.Run("Dropped", func(t *testing.T) {
+		s := newStore(t)
+		block := make(chan struct{})
+		done := make(chan error, 1)
+		started := make(chan struct{})
+		go func() {
+			var once bool
+			done <- s.Watch(ctx, cadata.Span{}, func(ev cadata.Event) error {
+				if !once {
+					once = true
+					close(started)
+				}
+				<-block
+				return nil
+			})
+		}()
+		post(t, s, 0)
+		<-started
+		for i := 1; i < 10*cadata.DefaultWatchBufferSize; i++ {
+			post(t, s, byte(i))
+		}
+		close(block)
+		require.ErrorIs(t, <-done, cadata.ErrEventsDropped)
+	})
+}
+
+func sortIDs(ids []cadata.ID) {
+	for i := 1; i < len(ids); i++ {
+		for j := i; j > 0 && ids[j-1].Compare(ids[j]) > 0; j-- {
+			ids[j-1], ids[j] = ids[j], ids[j-1]
+		}
+	}
+}
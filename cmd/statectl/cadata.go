@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sync"
+
+	"go.brendoncarroll.net/state/cadata"
+	"go.brendoncarroll.net/state/cadata/fsstore"
+	"go.brendoncarroll.net/state/posixfs"
+)
+
+var cadataCommands = map[string]command{
+	"post": {
+		Usage: "-dir DIR [FILE]: post FILE, or stdin, and print its ID",
+		Run:   cadataPost,
+	},
+	"get": {
+		Usage: "-dir DIR ID: write the data for ID to stdout",
+		Run:   cadataGet,
+	},
+	"ls": {
+		Usage: "-dir DIR: list all the IDs in the store",
+		Run:   cadataList,
+	},
+	"rm": {
+		Usage: "-dir DIR ID...: delete IDs from the store",
+		Run:   cadataRemove,
+	},
+	"verify": {
+		Usage: "-dir DIR: check that every blob matches its ID",
+		Run:   cadataVerify,
+	},
+	"copy": {
+		Usage: "-dir DIR -to DIR [ID...]: copy IDs, or everything, to another store",
+		Run:   cadataCopy,
+	},
+}
+
+type storeFlags struct {
+	dir     string
+	maxSize int
+}
+
+func newStoreFlags(name string) (*flag.FlagSet, *storeFlags) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	sf := &storeFlags{}
+	fs.StringVar(&sf.dir, "dir", "", "the fsstore directory")
+	fs.IntVar(&sf.maxSize, "max-size", cadata.DefaultMaxSize, "the maximum size of a blob")
+	return fs, sf
+}
+
+func (sf *storeFlags) open() (cadata.Store, error) {
+	return openStore(sf.dir, sf.maxSize)
+}
+
+func openStore(dir string, maxSize int) (cadata.Store, error) {
+	if dir == "" {
+		return nil, errors.New("-dir is required")
+	}
+	return fsstore.New(posixfs.NewDirFS(dir), cadata.DefaultHash, maxSize), nil
+}
+
+func cadataPost(e env, args []string) error {
+	fs, sf := newStoreFlags("post")
+	args, err := parseFlags(fs, args, 0, 1)
+	if err != nil {
+		return err
+	}
+	s, err := sf.open()
+	if err != nil {
+		return err
+	}
+	r := e.in
+	if len(args) > 0 && args[0] != "-" {
+		data, err := posixfs.ReadFile(e.ctx, posixfs.NewOSFS(), args[0])
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, int64(s.MaxSize())+1))
+	if err != nil {
+		return err
+	}
+	id, err := s.Post(e.ctx, data)
+	if err != nil {
+		return err
+	}
+	return e.print(idOutput{ID: id}, id.String())
+}
+
+func cadataGet(e env, args []string) error {
+	fs, sf := newStoreFlags("get")
+	args, err := parseFlags(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	s, err := sf.open()
+	if err != nil {
+		return err
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	data, err := cadata.GetBytes(e.ctx, s, id)
+	if err != nil {
+		return err
+	}
+	if e.json {
+		return e.printJSON(blobOutput{ID: id, Data: data})
+	}
+	_, err = e.out.Write(data)
+	return err
+}
+
+func cadataList(e env, args []string) error {
+	fs, sf := newStoreFlags("ls")
+	if _, err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	s, err := sf.open()
+	if err != nil {
+		return err
+	}
+	return cadata.ForEach(e.ctx, s, cadata.Span{}, func(id cadata.ID) error {
+		return e.print(idOutput{ID: id}, id.String())
+	})
+}
+
+func cadataRemove(e env, args []string) error {
+	fs, sf := newStoreFlags("rm")
+	args, err := parseFlags(fs, args, 1, -1)
+	if err != nil {
+		return err
+	}
+	s, err := sf.open()
+	if err != nil {
+		return err
+	}
+	for _, arg := range args {
+		id, err := parseID(arg)
+		if err != nil {
+			return err
+		}
+		if err := s.Delete(e.ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func cadataVerify(e env, args []string) error {
+	fs, sf := newStoreFlags("verify")
+	if _, err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	s, err := sf.open()
+	if err != nil {
+		return err
+	}
+	pool := sync.Pool{New: func() any {
+		buf := make([]byte, s.MaxSize())
+		return &buf
+	}}
+	var mu sync.Mutex
+	var total, bad int
+	if err := cadata.ParallelForEach(e.ctx, s, cadata.Span{}, cadata.ForEachParams{}, func(id cadata.ID) error {
+		bufp := pool.Get().(*[]byte)
+		defer pool.Put(bufp)
+		n, err := s.Get(e.ctx, id, *bufp)
+		if err == nil {
+			err = cadata.Check(s.Hash, id, (*bufp)[:n])
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		total++
+		if err != nil {
+			bad++
+			return e.print(verifyOutput{ID: id, Error: err.Error()}, fmt.Sprintf("%v: %v", id, err))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if bad > 0 {
+		return fmt.Errorf("%d of %d blobs failed verification", bad, total)
+	}
+	return nil
+}
+
+func cadataCopy(e env, args []string) error {
+	fs, sf := newStoreFlags("copy")
+	to := fs.String("to", "", "the destination fsstore directory")
+	args, err := parseFlags(fs, args, 0, -1)
+	if err != nil {
+		return err
+	}
+	src, err := sf.open()
+	if err != nil {
+		return err
+	}
+	dst, err := openStore(*to, sf.maxSize)
+	if err != nil {
+		return fmt.Errorf("destination: %w", err)
+	}
+	if len(args) == 0 {
+		return cadata.CopyAll(e.ctx, dst, src)
+	}
+	for _, arg := range args {
+		id, err := parseID(arg)
+		if err != nil {
+			return err
+		}
+		if err := cadata.Copy(e.ctx, dst, src, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseID(x string) (cadata.ID, error) {
+	var id cadata.ID
+	if err := id.UnmarshalBase64([]byte(x)); err != nil {
+		return cadata.ID{}, fmt.Errorf("invalid ID %q: %w", x, err)
+	}
+	return id, nil
+}
+
+type idOutput struct {
+	ID cadata.ID `json:"id"`
+}
+
+type blobOutput struct {
+	ID   cadata.ID `json:"id"`
+	Data []byte    `json:"data"`
+}
+
+type verifyOutput struct {
+	ID    cadata.ID `json:"id"`
+	Error string    `json:"error"`
+}
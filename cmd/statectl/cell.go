@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+
+	"go.brendoncarroll.net/state/cells"
+	"go.brendoncarroll.net/state/cells/httpcell"
+)
+
+var cellCommands = map[string]command{
+	"get": {
+		Usage: "URL: write the contents of the cell to stdout",
+		Run:   cellGet,
+	},
+	"cas": {
+		Usage: "URL PREV [NEXT]: swap the cell from PREV to NEXT, or stdin, and print the actual contents",
+		Run:   cellCAS,
+	},
+}
+
+func cellGet(e env, args []string) error {
+	fs := flag.NewFlagSet("get", flag.ContinueOnError)
+	args, err := parseFlags(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	cell := httpcell.New(httpcell.Spec{URL: args[0]})
+	data, err := cells.Load[[]byte](e.ctx, cell)
+	if err != nil {
+		return err
+	}
+	if e.json {
+		return e.printJSON(cellOutput{Data: data})
+	}
+	_, err = e.out.Write(data)
+	return err
+}
+
+func cellCAS(e env, args []string) error {
+	fs := flag.NewFlagSet("cas", flag.ContinueOnError)
+	args, err := parseFlags(fs, args, 2, 3)
+	if err != nil {
+		return err
+	}
+	cell := httpcell.New(httpcell.Spec{URL: args[0]})
+	prev := []byte(args[1])
+	var next []byte
+	if len(args) > 2 {
+		next = []byte(args[2])
+	} else if next, err = io.ReadAll(io.LimitReader(e.in, int64(cell.MaxSize())+1)); err != nil {
+		return err
+	}
+	var actual []byte
+	success, err := cell.CAS(e.ctx, &actual, prev, next)
+	if err != nil {
+		return err
+	}
+	if e.json {
+		err = e.printJSON(cellOutput{Data: actual, Success: &success})
+	} else {
+		_, err = e.out.Write(actual)
+	}
+	if err != nil {
+		return err
+	}
+	if !success {
+		return errors.New("CAS failed, the cell did not contain PREV")
+	}
+	return nil
+}
+
+type cellOutput struct {
+	Data    []byte `json:"data"`
+	Success *bool  `json:"success,omitempty"`
+}
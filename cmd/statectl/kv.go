@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"go.brendoncarroll.net/state"
+	"go.brendoncarroll.net/state/kv"
+	"go.brendoncarroll.net/state/kv/lsm"
+	"go.brendoncarroll.net/state/posixfs"
+)
+
+var kvCommands = map[string]command{
+	"get": {
+		Usage: "-dir DIR KEY: write the value at KEY to stdout",
+		Run:   kvGet,
+	},
+	"put": {
+		Usage: "-dir DIR KEY [VALUE]: set KEY to VALUE, or stdin",
+		Run:   kvPut,
+	},
+	"rm": {
+		Usage: "-dir DIR KEY...: delete keys",
+		Run:   kvRemove,
+	},
+	"ls": {
+		Usage: "-dir DIR [-gteq KEY] [-lt KEY]: list keys in order",
+		Run:   kvList,
+	},
+	"compact": {
+		Usage: "-dir DIR: merge all of the store's tables into one",
+		Run:   kvCompact,
+	},
+}
+
+type kvStore = lsm.Store[[]byte, []byte]
+
+func newKVFlags(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	dir := fs.String("dir", "", "the lsm store directory")
+	return fs, dir
+}
+
+// withKVStore opens the store in dir, calls fn, and then closes the store.
+// If readOnly is true, the store must already exist, and none of its files are changed.
+func withKVStore(e env, dir string, readOnly bool, fn func(s *kvStore) error) error {
+	if dir == "" {
+		return errors.New("-dir is required")
+	}
+	s, err := lsm.Open[[]byte, []byte](e.ctx, posixfs.NewDirFS(dir), lsm.Params[[]byte, []byte]{
+		KeyCodec:   kv.BytesCodec{},
+		ValueCodec: kv.BytesCodec{},
+		ReadOnly:   readOnly,
+	})
+	if posixfs.IsErrNotExist(err) {
+		return fmt.Errorf("no store in %s", dir)
+	} else if err != nil {
+		return err
+	}
+	if err := fn(s); err != nil {
+		s.Close()
+		return err
+	}
+	return s.Close()
+}
+
+func kvGet(e env, args []string) error {
+	fs, dir := newKVFlags("get")
+	args, err := parseFlags(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	return withKVStore(e, *dir, true, func(s *kvStore) error {
+		v, err := kv.Get[[]byte, []byte](e.ctx, s, []byte(args[0]))
+		if err != nil {
+			return err
+		}
+		if e.json {
+			return e.printJSON(entryOutput{Key: args[0], Value: v})
+		}
+		_, err = e.out.Write(v)
+		return err
+	})
+}
+
+func kvPut(e env, args []string) error {
+	fs, dir := newKVFlags("put")
+	args, err := parseFlags(fs, args, 1, 2)
+	if err != nil {
+		return err
+	}
+	var v []byte
+	if len(args) > 1 {
+		v = []byte(args[1])
+	} else if v, err = io.ReadAll(e.in); err != nil {
+		return err
+	}
+	return withKVStore(e, *dir, false, func(s *kvStore) error {
+		return s.Put(e.ctx, []byte(args[0]), v)
+	})
+}
+
+func kvRemove(e env, args []string) error {
+	fs, dir := newKVFlags("rm")
+	args, err := parseFlags(fs, args, 1, -1)
+	if err != nil {
+		return err
+	}
+	return withKVStore(e, *dir, false, func(s *kvStore) error {
+		return s.Modify(e.ctx, func(tx kv.Store[[]byte, []byte]) error {
+			for _, arg := range args {
+				if err := tx.Delete(e.ctx, []byte(arg)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+func kvList(e env, args []string) error {
+	fs, dir := newKVFlags("ls")
+	gteq := fs.String("gteq", "", "only list keys >= this")
+	lt := fs.String("lt", "", "only list keys < this")
+	if _, err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	span := state.TotalSpan[[]byte]()
+	if *gteq != "" {
+		span = span.WithLowerIncl([]byte(*gteq))
+	}
+	if *lt != "" {
+		span = span.WithUpperExcl([]byte(*lt))
+	}
+	return withKVStore(e, *dir, true, func(s *kvStore) error {
+		return kv.ForEach[[]byte](e.ctx, s, span, func(k []byte) error {
+			return e.print(entryOutput{Key: string(k)}, string(k))
+		})
+	})
+}
+
+func kvCompact(e env, args []string) error {
+	fs, dir := newKVFlags("compact")
+	if _, err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	return withKVStore(e, *dir, false, func(s *kvStore) error {
+		return s.Compact(e.ctx)
+	})
+}
+
+type entryOutput struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value,omitempty"`
+}
@@ -0,0 +1,119 @@
+// Command statectl inspects and manipulates stores and cells.
+//
+// Usage:
+//
+//	statectl [-json] cadata <post|get|ls|rm|verify|copy> [flags] [args]
+//	statectl [-json] cell <get|cas> [flags] [args]
+//	statectl [-json] kv <get|put|rm|ls|compact> [flags] [args]
+//
+// IDs are printed and parsed using cadata.Base64Alphabet.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+)
+
+func main() {
+	ctx, cf := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cf()
+	if err := run(ctx, os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "statectl:", err)
+		os.Exit(1)
+	}
+}
+
+// env is passed to every command
+type env struct {
+	ctx  context.Context
+	in   io.Reader
+	out  io.Writer
+	json bool
+}
+
+// print writes x as JSON if the -json flag was set, or text otherwise.
+func (e env) print(x interface{}, text string) error {
+	if e.json {
+		return e.printJSON(x)
+	}
+	_, err := fmt.Fprintln(e.out, text)
+	return err
+}
+
+// printJSON writes x as JSON on a single line.
+func (e env) printJSON(x interface{}) error {
+	return json.NewEncoder(e.out).Encode(x)
+}
+
+type command struct {
+	Usage string
+	Run   func(e env, args []string) error
+}
+
+var groups = map[string]map[string]command{
+	"cadata": cadataCommands,
+	"cell":   cellCommands,
+	"kv":     kvCommands,
+}
+
+func run(ctx context.Context, args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("statectl", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	jsonOut := fs.Bool("json", false, "print output as JSON, one value per line")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) < 2 {
+		return errors.New(usage())
+	}
+	cmds, ok := groups[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage())
+	}
+	cmd, ok := cmds[args[1]]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n%s", strings.Join(args[:2], " "), usage())
+	}
+	e := env{ctx: ctx, in: in, out: out, json: *jsonOut}
+	return cmd.Run(e, args[2:])
+}
+
+func usage() string {
+	sb := &strings.Builder{}
+	sb.WriteString("usage: statectl [-json] <command> [flags] [args]\ncommands:\n")
+	var names []string
+	for group, cmds := range groups {
+		for name := range cmds {
+			names = append(names, group+" "+name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts := strings.SplitN(name, " ", 2)
+		fmt.Fprintf(sb, "  %s %s\n", name, groups[parts[0]][parts[1]].Usage)
+	}
+	return sb.String()
+}
+
+// parseFlags parses args with fs, and checks that the number of positional arguments is in [min, max].
+// max < 0 means there is no maximum.
+func parseFlags(fs *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	args = fs.Args()
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		return nil, fmt.Errorf("wrong number of arguments for %s: %d", fs.Name(), len(args))
+	}
+	return args, nil
+}
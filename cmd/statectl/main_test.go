@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.brendoncarroll.net/state/cells/httpcell"
+)
+
+func TestCadata(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+
+	id := strings.TrimSpace(runOK(t, "hello world", "cadata", "post", "-dir", src))
+	require.Equal(t, "hello world", runOK(t, "", "cadata", "get", "-dir", src, id))
+	require.Equal(t, id+"\n", runOK(t, "", "cadata", "ls", "-dir", src))
+	runOK(t, "", "cadata", "verify", "-dir", src)
+
+	var out struct {
+		ID   string `json:"id"`
+		Data []byte `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(runOK(t, "", "-json", "cadata", "get", "-dir", src, id)), &out))
+	require.Equal(t, id, out.ID)
+	require.Equal(t, "hello world", string(out.Data))
+
+	runOK(t, "", "cadata", "copy", "-dir", src, "-to", dst)
+	require.Equal(t, id+"\n", runOK(t, "", "cadata", "ls", "-dir", dst))
+	runOK(t, "", "cadata", "rm", "-dir", dst, id)
+	require.Equal(t, "", runOK(t, "", "cadata", "ls", "-dir", dst))
+	runOK(t, "", "cadata", "copy", "-dir", src, "-to", dst, id)
+	require.Equal(t, id+"\n", runOK(t, "", "cadata", "ls", "-dir", dst))
+
+	_, err := runCmd("", "cadata", "get", "-dir", src, "not-an-id")
+	require.Error(t, err)
+}
+
+func TestKV(t *testing.T) {
+	dir := t.TempDir()
+	// reading a directory without a store fails, and does not create one.
+	_, err := runCmd("", "kv", "ls", "-dir", dir)
+	require.Error(t, err)
+	ents, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, ents)
+
+	runOK(t, "", "kv", "put", "-dir", dir, "a", "1")
+	runOK(t, "2", "kv", "put", "-dir", dir, "b")
+	runOK(t, "", "kv", "put", "-dir", dir, "c", "3")
+	require.Equal(t, "1", runOK(t, "", "kv", "get", "-dir", dir, "a"))
+	require.Equal(t, "2", runOK(t, "", "kv", "get", "-dir", dir, "b"))
+	require.Equal(t, "a\nb\nc\n", runOK(t, "", "kv", "ls", "-dir", dir))
+	require.Equal(t, "b\n", runOK(t, "", "kv", "ls", "-dir", dir, "-gteq", "b", "-lt", "c"))
+
+	runOK(t, "", "kv", "rm", "-dir", dir, "a", "c")
+	runOK(t, "", "kv", "compact", "-dir", dir)
+	require.Equal(t, "b\n", runOK(t, "", "kv", "ls", "-dir", dir))
+	_, err = runCmd("", "kv", "get", "-dir", dir, "a")
+	require.Error(t, err)
+}
+
+func TestCell(t *testing.T) {
+	ctx, cf := context.WithCancel(context.Background())
+	t.Cleanup(cf)
+	server := httpcell.NewServer()
+	go server.Serve(ctx, "127.0.0.1:")
+	spec := server.CreateCell("test")
+
+	require.Equal(t, "", runOK(t, "", "cell", "get", spec.URL))
+	require.Equal(t, "a", runOK(t, "", "cell", "cas", spec.URL, "", "a"))
+	require.Equal(t, "b", runOK(t, "b", "cell", "cas", spec.URL, "a"))
+	out, err := runCmd("", "cell", "cas", spec.URL, "a", "c")
+	require.Error(t, err)
+	require.Equal(t, "b", out)
+	require.Equal(t, "b", runOK(t, "", "cell", "get", spec.URL))
+}
+
+func runCmd(stdin string, args ...string) (string, error) {
+	out := &bytes.Buffer{}
+	err := run(context.Background(), args, strings.NewReader(stdin), out)
+	return out.String(), err
+}
+
+func runOK(t testing.TB, stdin string, args ...string) string {
+	out, err := runCmd(stdin, args...)
+	require.NoError(t, err)
+	return out
+}
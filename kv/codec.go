@@ -0,0 +1,55 @@
+package kv
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Codec converts values of type T to and from bytes.
+// Codecs used for keys must preserve order: bytes.Compare on the encodings
+// must agree with the order of the values.
+type Codec[T any] interface {
+	// Encode appends the encoding of x to out and returns the result.
+	Encode(out []byte, x T) []byte
+	// Decode parses data into a T.
+	// Decode must not retain data after it returns.
+	Decode(data []byte) (T, error)
+}
+
+// BytesCodec is the identity Codec for []byte. It preserves order.
+type BytesCodec struct{}
+
+func (BytesCodec) Encode(out []byte, x []byte) []byte {
+	return append(out, x...)
+}
+
+func (BytesCodec) Decode(data []byte) ([]byte, error) {
+	return append([]byte{}, data...), nil
+}
+
+// StringCodec encodes strings as their bytes. It preserves order.
+type StringCodec struct{}
+
+func (StringCodec) Encode(out []byte, x string) []byte {
+	return append(out, x...)
+}
+
+func (StringCodec) Decode(data []byte) (string, error) {
+	return string(data), nil
+}
+
+// Uint64Codec encodes uint64s as 8 big endian bytes. It preserves order.
+type Uint64Codec struct{}
+
+func (Uint64Codec) Encode(out []byte, x uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], x)
+	return append(out, buf[:]...)
+}
+
+func (Uint64Codec) Decode(data []byte) (uint64, error) {
+	if len(data) != 8 {
+		return 0, fmt.Errorf("uint64 must be 8 bytes, have %d", len(data))
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
@@ -0,0 +1,88 @@
+package kv_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.brendoncarroll.net/state/kv"
+	"go.brendoncarroll.net/state/kv/kvtest"
+	"go.brendoncarroll.net/state/posixfs"
+)
+
+func TestMemStoreConformance(t *testing.T) {
+	kvtest.TestStoreTx(t, func(t testing.TB) kvtest.StoreTx {
+		return kv.NewMemStore[[]byte, []byte](bytes.Compare)
+	})
+}
+
+func TestMVCCStoreConformance(t *testing.T) {
+	kvtest.TestStoreTx(t, func(t testing.TB) kvtest.StoreTx {
+		return kv.NewMVCCStore[[]byte, []byte](bytes.Compare)
+	})
+}
+
+func TestOptimisticStoreConformance(t *testing.T) {
+	kvtest.TestStoreTx(t, func(t testing.TB) kvtest.StoreTx {
+		return kv.NewOptimisticStore[[]byte, []byte](bytes.Compare)
+	})
+}
+
+func TestMemStoreWALConformance(t *testing.T) {
+	kvtest.TestStoreTx(t, func(t testing.TB) kvtest.StoreTx {
+		s, err := kv.OpenMemStore[[]byte, []byte](context.Background(), bytes.Compare, kv.WALParams[[]byte, []byte]{
+			FS:              posixfs.NewTestFS(t),
+			KeyCodec:        kv.BytesCodec{},
+			ValueCodec:      kv.BytesCodec{},
+			CheckpointEvery: 16,
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+func TestEncodedConformance(t *testing.T) {
+	t.Run("Store", func(t *testing.T) {
+		kvtest.TestStore(t, func(t testing.TB) kvtest.Store {
+			inner := kv.NewMemStore[[]byte, []byte](bytes.Compare)
+			return kv.NewEncoded[[]byte, []byte](inner, kv.BytesCodec{}, kv.BytesCodec{})
+		})
+	})
+	t.Run("StoreTx", func(t *testing.T) {
+		kvtest.TestStoreTx(t, func(t testing.TB) kvtest.StoreTx {
+			inner := kv.NewMemStore[[]byte, []byte](bytes.Compare)
+			return kv.NewEncodedTx[[]byte, []byte](inner, kv.BytesCodec{}, kv.BytesCodec{})
+		})
+	})
+}
+
+func TestPrefixedConformance(t *testing.T) {
+	for _, prefix := range []string{"users/", "\xff\xff"} {
+		prefix := []byte(prefix)
+		t.Run(fmt.Sprintf("%q/Store", prefix), func(t *testing.T) {
+			kvtest.TestStore(t, func(t testing.TB) kvtest.Store {
+				return kv.NewPrefixed[[]byte](newNeighbours(t), prefix)
+			})
+		})
+		t.Run(fmt.Sprintf("%q/StoreTx", prefix), func(t *testing.T) {
+			kvtest.TestStoreTx(t, func(t testing.TB) kvtest.StoreTx {
+				return kv.NewPrefixedTx[[]byte](newNeighbours(t), prefix)
+			})
+		})
+	}
+}
+
+// newNeighbours returns a MemStore holding keys just outside of the namespaces in TestPrefixedConformance,
+// which must not be visible through them.
+func newNeighbours(t testing.TB) *kv.MemStore[[]byte, []byte] {
+	ctx := context.Background()
+	s := kv.NewMemStore[[]byte, []byte](bytes.Compare)
+	for _, k := range []string{"", "\x00", "user/", "user/a", "users", "users.", "users0", "users0/a", "\xff", "\xff\xfe\xff"} {
+		require.NoError(t, s.Put(ctx, []byte(k), []byte("neighbour")))
+	}
+	return s
+}
@@ -0,0 +1,140 @@
+package kv
+
+import (
+	"context"
+
+	"go.brendoncarroll.net/state"
+)
+
+var _ Store[int, int] = &encodedStore[int, int]{}
+var _ StoreTx[int, int] = &encodedStoreTx[int, int]{}
+
+// NewEncoded returns a Store which keeps its entries in inner, encoded with kc and vc.
+// kc must preserve order, so that spans of keys can be converted to spans of encoded keys.
+func NewEncoded[K, V any](inner Store[[]byte, []byte], kc Codec[K], vc Codec[V]) Store[K, V] {
+	return &encodedStore[K, V]{r: inner, w: inner, kc: kc, vc: vc}
+}
+
+// NewEncodedTx returns a StoreTx which keeps its entries in inner, encoded with kc and vc.
+// Each transaction on the returned store is a transaction on inner.
+// kc must preserve order.
+func NewEncodedTx[K, V any](inner StoreTx[[]byte, []byte], kc Codec[K], vc Codec[V]) StoreTx[K, V] {
+	return &encodedStoreTx[K, V]{inner: inner, kc: kc, vc: vc}
+}
+
+// EncodeSpan converts a span of keys to the span of their encodings under kc.
+// kc must preserve order.
+func EncodeSpan[K any](span state.Span[K], kc Codec[K]) state.Span[[]byte] {
+	var ret state.Span[[]byte]
+	if lower, ok := span.LowerBound(); ok {
+		if span.IncludesLower() {
+			ret = ret.WithLowerIncl(kc.Encode(nil, lower))
+		} else {
+			ret = ret.WithLowerExcl(kc.Encode(nil, lower))
+		}
+	}
+	if upper, ok := span.UpperBound(); ok {
+		if span.IncludesUpper() {
+			ret = ret.WithUpperIncl(kc.Encode(nil, upper))
+		} else {
+			ret = ret.WithUpperExcl(kc.Encode(nil, upper))
+		}
+	}
+	if span.IsDesc() {
+		ret = ret.Desc()
+	}
+	return ret
+}
+
+type encodedStoreTx[K, V any] struct {
+	inner StoreTx[[]byte, []byte]
+	kc    Codec[K]
+	vc    Codec[V]
+}
+
+func (s *encodedStoreTx[K, V]) View(ctx context.Context, fn func(ReadOnlyStore[K, V]) error) error {
+	return s.inner.View(ctx, func(tx ReadOnlyStore[[]byte, []byte]) error {
+		return fn(&encodedStore[K, V]{r: tx, kc: s.kc, vc: s.vc})
+	})
+}
+
+func (s *encodedStoreTx[K, V]) Modify(ctx context.Context, fn func(Store[K, V]) error) error {
+	return s.inner.Modify(ctx, func(tx Store[[]byte, []byte]) error {
+		return fn(&encodedStore[K, V]{r: tx, w: tx, kc: s.kc, vc: s.vc})
+	})
+}
+
+// encodedStore implements Store on top of a byte store.
+// w is nil if the store is read only.
+type encodedStore[K, V any] struct {
+	r  ReadOnlyStore[[]byte, []byte]
+	w  Store[[]byte, []byte]
+	kc Codec[K]
+	vc Codec[V]
+}
+
+func (s *encodedStore[K, V]) Put(ctx context.Context, k K, v V) error {
+	return s.w.Put(ctx, s.kc.Encode(nil, k), s.vc.Encode(nil, v))
+}
+
+func (s *encodedStore[K, V]) Delete(ctx context.Context, k K) error {
+	return s.w.Delete(ctx, s.kc.Encode(nil, k))
+}
+
+func (s *encodedStore[K, V]) DeleteSpan(ctx context.Context, span state.Span[K]) (int, error) {
+	return DeleteSpan[[]byte](ctx, s.w, EncodeSpan(span, s.kc))
+}
+
+func (s *encodedStore[K, V]) Get(ctx context.Context, k K, dst *V) error {
+	var data []byte
+	if err := s.r.Get(ctx, s.kc.Encode(nil, k), &data); err != nil {
+		if state.IsErrNotFound[[]byte](err) {
+			return state.ErrNotFound[K]{Key: k}
+		}
+		return err
+	}
+	v, err := s.vc.Decode(data)
+	if err != nil {
+		return err
+	}
+	*dst = v
+	return nil
+}
+
+func (s *encodedStore[K, V]) Exists(ctx context.Context, k K) (bool, error) {
+	if e, ok := s.r.(Exister[[]byte]); ok {
+		return e.Exists(ctx, s.kc.Encode(nil, k))
+	}
+	return ExistsUsingList[K](ctx, s, k)
+}
+
+func (s *encodedStore[K, V]) List(ctx context.Context, span state.Span[K], buf []K) (int, error) {
+	keys := make([][]byte, len(buf))
+	n, err := s.r.List(ctx, EncodeSpan(span, s.kc), keys)
+	if err != nil {
+		return 0, err
+	}
+	for i := range keys[:n] {
+		if buf[i], err = s.kc.Decode(keys[i]); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+func (s *encodedStore[K, V]) ListEntries(ctx context.Context, span state.Span[K], buf []Entry[K, V]) (int, error) {
+	ents := make([]Entry[[]byte, []byte], len(buf))
+	n, err := ListEntries(ctx, s.r, EncodeSpan(span, s.kc), ents)
+	if err != nil {
+		return 0, err
+	}
+	for i, ent := range ents[:n] {
+		if buf[i].Key, err = s.kc.Decode(ent.Key); err != nil {
+			return 0, err
+		}
+		if buf[i].Value, err = s.vc.Decode(ent.Value); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,123 @@
+// Package wal implements an append only log of checksummed records on a posixfs.FS.
+//
+// Each record is framed as:
+//
+//	length uint32 | crc32(payload) uint32 | payload
+//
+// with integers in big endian.
+// A record which is incomplete or fails its checksum marks the end of the log.
+// That is how a write torn by a crash looks, so it is discarded on replay.
+package wal
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+
+	"go.brendoncarroll.net/state/posixfs"
+)
+
+const headerSize = 8
+
+// MaxRecordSize is the largest payload that can be written to the log.
+const MaxRecordSize = 1 << 30
+
+var ErrTooLarge = errors.New("wal: record is too large")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Writer appends records to a log file.
+type Writer struct {
+	f   posixfs.File
+	buf []byte
+}
+
+// Create creates a new, empty, log at p.
+// It is an error if p already exists.
+func Create(fsx posixfs.FS, p string) (*Writer, error) {
+	f, err := fsx.OpenFile(p, posixfs.O_WRONLY|posixfs.O_CREATE|posixfs.O_EXCL|posixfs.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{f: f}, nil
+}
+
+// Append writes a record containing payload to the end of the log.
+// The record is not durable until Sync returns.
+func (w *Writer) Append(payload []byte) error {
+	if len(payload) > MaxRecordSize {
+		return ErrTooLarge
+	}
+	w.buf = appendRecord(w.buf[:0], payload)
+	_, err := w.f.Write(w.buf)
+	return err
+}
+
+// Sync flushes all the records appended so far to stable storage.
+func (w *Writer) Sync() error {
+	return w.f.Sync()
+}
+
+func (w *Writer) Close() error {
+	return w.f.Close()
+}
+
+// Replay calls fn with the payload of each record in the log at p, in order.
+// Replay stops without an error at the first incomplete or corrupt record.
+// It returns the number of records passed to fn.
+// The payload passed to fn is only valid until fn returns.
+func Replay(ctx context.Context, fsx posixfs.FS, p string, fn func(payload []byte) error) (int, error) {
+	f, err := fsx.OpenFile(p, posixfs.O_RDONLY, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	var count int
+	var header [headerSize]byte
+	var payload []byte
+	for {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return count, nil
+			}
+			return count, err
+		}
+		n := binary.BigEndian.Uint32(header[0:4])
+		sum := binary.BigEndian.Uint32(header[4:8])
+		if n > MaxRecordSize {
+			return count, nil
+		}
+		if cap(payload) < int(n) {
+			payload = make([]byte, n)
+		}
+		payload = payload[:n]
+		if _, err := io.ReadFull(br, payload); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return count, nil
+			}
+			return count, err
+		}
+		if crc32.Checksum(payload, crcTable) != sum {
+			return count, nil
+		}
+		if err := fn(payload); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+func appendRecord(out []byte, payload []byte) []byte {
+	var header [headerSize]byte
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(header[4:8], crc32.Checksum(payload, crcTable))
+	out = append(out, header[:]...)
+	return append(out, payload...)
+}
@@ -0,0 +1,64 @@
+package wal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.brendoncarroll.net/state/posixfs"
+)
+
+func TestReplay(t *testing.T) {
+	fsx := posixfs.NewTestFS(t)
+	w, err := Create(fsx, "log")
+	require.NoError(t, err)
+	var expected []string
+	for i := 0; i < 100; i++ {
+		rec := fmt.Sprintf("record-%d", i)
+		require.NoError(t, w.Append([]byte(rec)))
+		expected = append(expected, rec)
+	}
+	require.NoError(t, w.Append(nil))
+	expected = append(expected, "")
+	require.NoError(t, w.Sync())
+	require.NoError(t, w.Close())
+
+	require.Equal(t, expected, replayAll(t, fsx, "log"))
+
+	_, err = Create(fsx, "log")
+	require.True(t, posixfs.IsErrExist(err))
+}
+
+func TestTornRecord(t *testing.T) {
+	ctx := context.Background()
+	good := appendRecord(nil, []byte("good"))
+	last := appendRecord(nil, []byte("last record"))
+	for i := 1; i < len(last); i++ {
+		fsx := posixfs.NewTestFS(t)
+		data := append(append([]byte{}, good...), last[:i]...)
+		require.NoError(t, posixfs.PutFile(ctx, fsx, "log", 0o644, bytes.NewReader(data)))
+		require.Equal(t, []string{"good"}, replayAll(t, fsx, "log"), "truncated to %d", i)
+	}
+
+	// a corrupt record ends the log.
+	fsx := posixfs.NewTestFS(t)
+	corrupt := append([]byte{}, last...)
+	corrupt[len(corrupt)-1] ^= 1
+	data := append(append(append([]byte{}, good...), corrupt...), good...)
+	require.NoError(t, posixfs.PutFile(ctx, fsx, "log", 0o644, bytes.NewReader(data)))
+	require.Equal(t, []string{"good"}, replayAll(t, fsx, "log"))
+}
+
+func replayAll(t testing.TB, fsx posixfs.FS, p string) []string {
+	var ret []string
+	n, err := Replay(context.Background(), fsx, p, func(payload []byte) error {
+		ret = append(ret, string(payload))
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, len(ret), n)
+	return ret
+}
@@ -0,0 +1,375 @@
+// Package kvtest provides conformance tests for implementations of kv.Store and kv.StoreTx.
+package kvtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.brendoncarroll.net/state"
+	"go.brendoncarroll.net/state/kv"
+)
+
+type (
+	Store   = kv.Store[[]byte, []byte]
+	StoreTx = kv.StoreTx[[]byte, []byte]
+)
+
+// TestStore runs the conformance tests for a kv.Store.
+// newStore should return a new, empty, Store each time it is called.
+func TestStore(t *testing.T, newStore func(t testing.TB) Store) {
+	t.Run("PutGet", func(t *testing.T) {
+		s := newStore(t)
+		put(t, s, "a", "1")
+		require.Equal(t, "1", get(t, s, "a"))
+	})
+	t.Run("GetNotFound", func(t *testing.T) {
+		s := newStore(t)
+		var v []byte
+		err := s.Get(ctx(), []byte("absent"), &v)
+		require.True(t, state.IsErrNotFound[[]byte](err), "%v", err)
+	})
+	t.Run("Overwrite", func(t *testing.T) {
+		s := newStore(t)
+		put(t, s, "a", "1")
+		put(t, s, "a", "2")
+		require.Equal(t, "2", get(t, s, "a"))
+		require.Equal(t, []string{"a"}, listAll(t, s, state.TotalSpan[[]byte](), 10))
+	})
+	t.Run("Delete", func(t *testing.T) {
+		s := newStore(t)
+		put(t, s, "a", "1")
+		put(t, s, "b", "2")
+		require.NoError(t, s.Delete(ctx(), []byte("a")))
+		require.False(t, exists(t, s, "a"))
+		require.True(t, exists(t, s, "b"))
+		require.Equal(t, []string{"b"}, listAll(t, s, state.TotalSpan[[]byte](), 10))
+	})
+	t.Run("DeleteAbsent", func(t *testing.T) {
+		s := newStore(t)
+		require.NoError(t, s.Delete(ctx(), []byte("absent")))
+	})
+	t.Run("EmptyValue", func(t *testing.T) {
+		s := newStore(t)
+		put(t, s, "a", "")
+		require.True(t, exists(t, s, "a"))
+		require.Equal(t, "", get(t, s, "a"))
+	})
+	t.Run("ListEmpty", func(t *testing.T) {
+		s := newStore(t)
+		require.Empty(t, listAll(t, s, state.TotalSpan[[]byte](), 10))
+	})
+	t.Run("ListSpan", func(t *testing.T) {
+		s := newStore(t)
+		var keys []string
+		for i := 0; i < 100; i++ {
+			k := fmt.Sprintf("%03d", i)
+			put(t, s, k, k)
+			keys = append(keys, k)
+		}
+		b := func(x string) []byte { return []byte(x) }
+		for _, tc := range []struct {
+			span     state.Span[[]byte]
+			expected []string
+		}{
+			{state.TotalSpan[[]byte](), keys},
+			{state.TotalSpan[[]byte]().WithLowerIncl(b("050")), keys[50:]},
+			{state.TotalSpan[[]byte]().WithLowerExcl(b("050")), keys[51:]},
+			{state.TotalSpan[[]byte]().WithUpperIncl(b("050")), keys[:51]},
+			{state.TotalSpan[[]byte]().WithUpperExcl(b("050")), keys[:50]},
+			{state.TotalSpan[[]byte]().WithLowerIncl(b("01")).WithUpperExcl(b("02")), keys[10:20]},
+			{state.PointSpan(b("077")), keys[77:78]},
+			{state.PointSpan(b("77")), nil},
+			{state.TotalSpan[[]byte]().WithLowerIncl(b("1")), nil},
+			{state.DescSpan[[]byte](), reverse(keys)},
+			{state.DescSpan[[]byte]().WithUpperIncl(b("050")), reverse(keys[:51])},
+			{state.DescSpan[[]byte]().WithLowerExcl(b("050")), reverse(keys[51:])},
+			{state.DescSpan[[]byte]().WithLowerIncl(b("01")).WithUpperExcl(b("02")), reverse(keys[10:20])},
+			{state.PointSpan(b("077")).Desc(), keys[77:78]},
+			{state.DescSpan[[]byte]().WithUpperExcl(b("000")), nil},
+		} {
+			for _, batchSize := range []int{1, 7, 1000} {
+				actual := listAll(t, s, tc.span, batchSize)
+				if tc.expected == nil {
+					require.Empty(t, actual, "span=%v", tc.span)
+				} else {
+					require.Equal(t, tc.expected, actual, "span=%v", tc.span)
+				}
+			}
+		}
+	})
+	t.Run("ForEach", func(t *testing.T) {
+		s := newStore(t)
+		for i := 0; i < 50; i++ {
+			put(t, s, fmt.Sprint(i), "")
+		}
+		var count int
+		err := kv.ForEach[[]byte](ctx(), s, state.TotalSpan[[]byte](), func([]byte) error {
+			count++
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 50, count)
+	})
+	t.Run("ListEntries", func(t *testing.T) {
+		s := newStore(t)
+		for i := 0; i < 50; i++ {
+			k := fmt.Sprintf("%03d", i)
+			put(t, s, k, "v"+k)
+		}
+		b := func(x string) []byte { return []byte(x) }
+		for _, span := range []state.Span[[]byte]{
+			state.TotalSpan[[]byte](),
+			state.DescSpan[[]byte](),
+			state.TotalSpan[[]byte]().WithLowerIncl(b("010")).WithUpperExcl(b("020")),
+			state.DescSpan[[]byte]().WithLowerExcl(b("010")).WithUpperIncl(b("020")),
+			state.PointSpan(b("100")),
+		} {
+			require.Equal(t, listAll(t, s, span, 7), listAllEntries(t, s, span), "span=%v", span)
+		}
+		buf := make([]kv.Entry[[]byte, []byte], 5)
+		n, err := kv.ListEntries[[]byte, []byte](ctx(), s, state.TotalSpan[[]byte](), buf)
+		require.NoError(t, err)
+		require.Equal(t, 5, n)
+		require.Equal(t, "004", string(buf[4].Key))
+		require.Equal(t, "v004", string(buf[4].Value))
+	})
+	t.Run("DeleteSpan", func(t *testing.T) {
+		s := newStore(t)
+		var keys []string
+		for i := 0; i < 100; i++ {
+			k := fmt.Sprintf("%03d", i)
+			put(t, s, k, k)
+			keys = append(keys, k)
+		}
+		span := state.TotalSpan[[]byte]().WithLowerIncl([]byte("010")).WithUpperExcl([]byte("090"))
+		n, err := kv.DeleteSpan[[]byte](ctx(), s, span)
+		require.NoError(t, err)
+		require.Equal(t, 80, n)
+		expected := append(append([]string{}, keys[:10]...), keys[90:]...)
+		require.Equal(t, expected, listAll(t, s, state.TotalSpan[[]byte](), 7))
+
+		n, err = kv.DeleteSpan[[]byte](ctx(), s, span)
+		require.NoError(t, err)
+		require.Equal(t, 0, n)
+		n, err = kv.DeleteSpan[[]byte](ctx(), s, state.DescSpan[[]byte]())
+		require.NoError(t, err)
+		require.Equal(t, 20, n)
+		require.Empty(t, listAll(t, s, state.TotalSpan[[]byte](), 7))
+	})
+	t.Run("Random", func(t *testing.T) {
+		s := newStore(t)
+		testRandom(t, s)
+	})
+}
+
+// TestStoreTx runs the conformance tests for a kv.StoreTx.
+// newStore should return a new, empty, StoreTx each time it is called.
+func TestStoreTx(t *testing.T, newStore func(t testing.TB) StoreTx) {
+	t.Run("Store", func(t *testing.T) {
+		TestStore(t, func(t testing.TB) Store {
+			return kv.FromStoreTx[[]byte, []byte](newStore(t))
+		})
+	})
+	t.Run("Commit", func(t *testing.T) {
+		s := newStore(t)
+		err := s.Modify(ctx(), func(tx Store) error {
+			put(t, tx, "a", "1")
+			put(t, tx, "b", "2")
+			return tx.Delete(ctx(), []byte("b"))
+		})
+		require.NoError(t, err)
+		err = s.View(ctx(), func(tx kv.ReadOnlyStore[[]byte, []byte]) error {
+			require.Equal(t, "1", get(t, tx, "a"))
+			require.Equal(t, []string{"a"}, listAll(t, tx, state.TotalSpan[[]byte](), 10))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+	t.Run("Rollback", func(t *testing.T) {
+		s := newStore(t)
+		err := s.Modify(ctx(), func(tx Store) error {
+			put(t, tx, "a", "1")
+			return nil
+		})
+		require.NoError(t, err)
+		errAbort := fmt.Errorf("abort")
+		err = s.Modify(ctx(), func(tx Store) error {
+			put(t, tx, "a", "2")
+			put(t, tx, "b", "2")
+			require.NoError(t, tx.Delete(ctx(), []byte("a")))
+			return errAbort
+		})
+		require.ErrorIs(t, err, errAbort)
+		err = s.View(ctx(), func(tx kv.ReadOnlyStore[[]byte, []byte]) error {
+			require.Equal(t, "1", get(t, tx, "a"))
+			require.Equal(t, []string{"a"}, listAll(t, tx, state.TotalSpan[[]byte](), 10))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+	t.Run("DeleteSpanRollback", func(t *testing.T) {
+		s := newStore(t)
+		err := s.Modify(ctx(), func(tx Store) error {
+			put(t, tx, "a", "1")
+			put(t, tx, "b", "1")
+			return nil
+		})
+		require.NoError(t, err)
+		errAbort := fmt.Errorf("abort")
+		err = s.Modify(ctx(), func(tx Store) error {
+			put(t, tx, "c", "1")
+			n, err := kv.DeleteSpan[[]byte](ctx(), tx, state.TotalSpan[[]byte]())
+			require.NoError(t, err)
+			require.Equal(t, 3, n)
+			require.Empty(t, listAll(t, tx, state.TotalSpan[[]byte](), 10))
+			return errAbort
+		})
+		require.ErrorIs(t, err, errAbort)
+		err = s.View(ctx(), func(tx kv.ReadOnlyStore[[]byte, []byte]) error {
+			require.Equal(t, []string{"a", "b"}, listAll(t, tx, state.TotalSpan[[]byte](), 10))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+	t.Run("ReadYourWrites", func(t *testing.T) {
+		s := newStore(t)
+		err := s.Modify(ctx(), func(tx Store) error {
+			for i := 0; i < 10; i++ {
+				put(t, tx, fmt.Sprint(i), "before")
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		err = s.Modify(ctx(), func(tx Store) error {
+			put(t, tx, "3", "after")
+			put(t, tx, "a", "new")
+			require.NoError(t, tx.Delete(ctx(), []byte("5")))
+
+			require.Equal(t, "after", get(t, tx, "3"))
+			require.Equal(t, "new", get(t, tx, "a"))
+			require.False(t, exists(t, tx, "5"))
+			require.True(t, exists(t, tx, "a"))
+			expected := []string{"0", "1", "2", "3", "4", "6", "7", "8", "9", "a"}
+			require.Equal(t, expected, listAll(t, tx, state.TotalSpan[[]byte](), 3))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+	t.Run("Random", func(t *testing.T) {
+		s := newStore(t)
+		err := s.Modify(ctx(), func(tx Store) error {
+			testRandom(t, tx)
+			return nil
+		})
+		require.NoError(t, err)
+	})
+}
+
+// testRandom applies random operations to s, and checks it against a map.
+func testRandom(t testing.TB, s Store) {
+	rng := rand.New(rand.NewSource(0))
+	model := map[string]string{}
+	for i := 0; i < 500; i++ {
+		k := fmt.Sprintf("%02x", rng.Intn(64))
+		switch rng.Intn(3) {
+		case 0, 1:
+			v := fmt.Sprint(i)
+			put(t, s, k, v)
+			model[k] = v
+		case 2:
+			require.NoError(t, s.Delete(ctx(), []byte(k)))
+			delete(model, k)
+		}
+		if i%50 == 0 {
+			var keys []string
+			for k := range model {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			require.Equal(t, keys, listAll(t, s, state.TotalSpan[[]byte](), 5))
+			require.Equal(t, reverse(keys), listAll(t, s, state.DescSpan[[]byte](), 5))
+			err := kv.ForEachEntry[[]byte, []byte](ctx(), s, state.TotalSpan[[]byte](), func(ent kv.Entry[[]byte, []byte]) error {
+				require.Equal(t, model[string(ent.Key)], string(ent.Value))
+				return nil
+			})
+			require.NoError(t, err)
+			for _, k := range keys {
+				require.Equal(t, model[k], get(t, s, k))
+			}
+		}
+	}
+}
+
+func ctx() context.Context {
+	return context.Background()
+}
+
+func put(t testing.TB, s kv.Putter[[]byte, []byte], k, v string) {
+	require.NoError(t, s.Put(ctx(), []byte(k), []byte(v)))
+}
+
+func get(t testing.TB, s kv.Getter[[]byte, []byte], k string) string {
+	var v []byte
+	require.NoError(t, s.Get(ctx(), []byte(k), &v))
+	return string(v)
+}
+
+func exists(t testing.TB, s kv.Lister[[]byte], k string) bool {
+	var yes bool
+	var err error
+	if e, ok := s.(kv.Exister[[]byte]); ok {
+		yes, err = e.Exists(ctx(), []byte(k))
+	} else {
+		yes, err = kv.ExistsUsingList[[]byte](ctx(), s, []byte(k))
+	}
+	require.NoError(t, err)
+	return yes
+}
+
+func listAll(t testing.TB, s kv.Lister[[]byte], span state.Span[[]byte], batchSize int) []string {
+	var ret []string
+	buf := make([][]byte, batchSize)
+	for {
+		n, err := s.List(ctx(), span, buf)
+		require.NoError(t, err)
+		if n == 0 {
+			return ret
+		}
+		for i, k := range buf[:n] {
+			require.True(t, span.Contains(k, bytes.Compare))
+			if i > 0 && span.IsDesc() {
+				require.Greater(t, string(buf[i-1]), string(k))
+			} else if i > 0 {
+				require.Less(t, string(buf[i-1]), string(k))
+			}
+			ret = append(ret, string(k))
+		}
+		span = span.After(append([]byte{}, buf[n-1]...))
+	}
+}
+
+// listAllEntries reads the entries in span with ForEachEntry, checks that each value is "v" + its key, and returns the keys.
+func listAllEntries(t testing.TB, s Store, span state.Span[[]byte]) []string {
+	var ret []string
+	err := kv.ForEachEntry[[]byte, []byte](ctx(), s, span, func(ent kv.Entry[[]byte, []byte]) error {
+		require.Equal(t, "v"+string(ent.Key), string(ent.Value))
+		ret = append(ret, string(ent.Key))
+		return nil
+	})
+	require.NoError(t, err)
+	return ret
+}
+
+func reverse(xs []string) []string {
+	ret := make([]string, len(xs))
+	for i := range xs {
+		ret[len(xs)-1-i] = xs[i]
+	}
+	return ret
+}
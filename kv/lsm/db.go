@@ -0,0 +1,383 @@
+package lsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.brendoncarroll.net/state"
+	"go.brendoncarroll.net/state/kv/internal/wal"
+	"go.brendoncarroll.net/state/posixfs"
+)
+
+var (
+	errClosed   = errors.New("lsm: store is closed")
+	errReadOnly = errors.New("lsm: store is read only")
+)
+
+type dbParams struct {
+	memtableSize int
+	maxTables    int
+	noSync       bool
+	readOnly     bool
+}
+
+// db is an ordered map of []byte to []byte.
+type db struct {
+	fsx posixfs.FS
+	p   dbParams
+
+	mu     sync.RWMutex
+	man    *manifest
+	mem    *memtable
+	tables []*sstable
+	wal    *wal.Writer
+	// err is set if a write fails part way through, the db can not be written to after that.
+	err    error
+	closed bool
+}
+
+func openDB(ctx context.Context, fsx posixfs.FS, p dbParams) (*db, error) {
+	man, err := loadManifest(ctx, fsx)
+	if posixfs.IsErrNotExist(err) && !p.readOnly {
+		man = &manifest{Version: manifestVersion, NextFile: 1}
+	} else if err != nil {
+		return nil, err
+	}
+	d := &db{fsx: fsx, p: p, man: man, mem: newMemtable()}
+	for _, n := range man.Tables {
+		t, err := openSSTable(fsx, tablePath(n), n)
+		if err != nil {
+			d.closeFiles()
+			return nil, err
+		}
+		d.tables = append(d.tables, t)
+	}
+	if man.WAL != 0 {
+		_, err := wal.Replay(ctx, fsx, walPath(man.WAL), func(payload []byte) error {
+			return applyBatch(d.mem, payload)
+		})
+		if err != nil && !posixfs.IsErrNotExist(err) {
+			d.closeFiles()
+			return nil, err
+		}
+	}
+	if p.readOnly {
+		return d, nil
+	}
+	// Files left by a crash during rotate or compact may use numbers which the manifest will allocate again,
+	// so they are removed before any new files are created.
+	if err := d.removeUnused(); err != nil {
+		d.closeFiles()
+		return nil, err
+	}
+	// Always start a new log, so new records are never written after a torn one.
+	if err := d.rotate(); err != nil {
+		d.closeFiles()
+		return nil, err
+	}
+	return d, nil
+}
+
+// rotate writes the memtable to a new table if it is not empty, and starts a new log.
+// d.mu must be held for writing.
+func (d *db) rotate() error {
+	man := *d.man
+	man.Tables = append([]uint64{}, d.man.Tables...)
+	var newTable *sstable
+	if d.mem.len() > 0 {
+		n := man.allocFile()
+		// tombstones are only needed to hide entries in older tables.
+		count, err := writeSSTable(d.fsx, tablePath(n), d.mem.iter(state.TotalSpan[[]byte]()), len(d.tables) == 0)
+		if err != nil {
+			return d.fail(err)
+		}
+		if count > 0 {
+			if newTable, err = openSSTable(d.fsx, tablePath(n), n); err != nil {
+				return d.fail(err)
+			}
+			man.Tables = append([]uint64{n}, man.Tables...)
+		}
+	}
+	walNum := man.allocFile()
+	w, err := wal.Create(d.fsx, walPath(walNum))
+	if err != nil {
+		if newTable != nil {
+			newTable.close()
+		}
+		return d.fail(err)
+	}
+	oldWAL := man.WAL
+	man.WAL = walNum
+	if err := saveManifest(d.fsx, &man); err != nil {
+		w.Close()
+		if newTable != nil {
+			newTable.close()
+		}
+		return d.fail(err)
+	}
+	d.man = &man
+	if newTable != nil {
+		d.tables = append([]*sstable{newTable}, d.tables...)
+	}
+	d.mem = newMemtable()
+	if d.wal != nil {
+		d.wal.Close()
+	}
+	d.wal = w
+	if oldWAL != 0 {
+		return removeFile(d.fsx, walPath(oldWAL))
+	}
+	return nil
+}
+
+// compact merges all of the tables into one.
+// d.mu must be held for writing.
+func (d *db) compact() error {
+	if len(d.tables) < 2 {
+		return nil
+	}
+	man := *d.man
+	var its []iterator
+	for _, t := range d.tables {
+		its = append(its, t.iter(state.TotalSpan[[]byte]()))
+	}
+	n := man.allocFile()
+	// the output contains every table, so tombstones have nothing left to hide.
+	count, err := writeSSTable(d.fsx, tablePath(n), newMergeIter(its, false), true)
+	if err != nil {
+		return d.fail(err)
+	}
+	var newTables []*sstable
+	man.Tables = nil
+	if count > 0 {
+		t, err := openSSTable(d.fsx, tablePath(n), n)
+		if err != nil {
+			return d.fail(err)
+		}
+		newTables = []*sstable{t}
+		man.Tables = []uint64{n}
+	}
+	if err := saveManifest(d.fsx, &man); err != nil {
+		for _, t := range newTables {
+			t.close()
+		}
+		return d.fail(err)
+	}
+	oldTables := d.tables
+	d.man = &man
+	d.tables = newTables
+	for _, t := range oldTables {
+		t.close()
+		if err := removeFile(d.fsx, tablePath(t.num)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commit durably writes the entries in pending, and applies them to the memtable.
+// d.mu must be held for writing.
+func (d *db) commit(pending *memtable) error {
+	if pending.len() == 0 {
+		return nil
+	}
+	var batch []byte
+	pending.tree.Ascend(func(e entry) bool {
+		batch = appendEntry(batch, e)
+		return true
+	})
+	if err := d.wal.Append(batch); err != nil {
+		return d.fail(err)
+	}
+	if !d.p.noSync {
+		if err := d.wal.Sync(); err != nil {
+			return d.fail(err)
+		}
+	}
+	pending.tree.Ascend(func(e entry) bool {
+		d.mem.insert(e)
+		return true
+	})
+	// The transaction is durable now, so it has succeeded even if the flush below fails.
+	// A failed flush is recorded, and reported by the next write.
+	if err := d.flush(); err != nil && d.err == nil {
+		d.fail(err)
+	}
+	return nil
+}
+
+// flush rotates and compacts, if the memtable or the number of tables has grown too large.
+// d.mu must be held for writing.
+func (d *db) flush() error {
+	if d.mem.size < d.p.memtableSize {
+		return nil
+	}
+	if err := d.rotate(); err != nil {
+		return err
+	}
+	if len(d.tables) > d.p.maxTables {
+		return d.compact()
+	}
+	return nil
+}
+
+// fail records that a write failed part way through, and returns err.
+func (d *db) fail(err error) error {
+	d.err = fmt.Errorf("lsm: store is unusable after failed write: %w", err)
+	return err
+}
+
+// checkWritable returns an error if the db cannot be modified.
+func (d *db) checkWritable() error {
+	if d.closed {
+		return errClosed
+	}
+	if d.p.readOnly {
+		return errReadOnly
+	}
+	return d.err
+}
+
+func (d *db) get(key []byte) (entry, bool, error) {
+	if e, ok := d.mem.get(key); ok {
+		return e, true, nil
+	}
+	for _, t := range d.tables {
+		e, ok, err := t.get(key)
+		if err != nil {
+			return entry{}, false, err
+		}
+		if ok {
+			return e, true, nil
+		}
+	}
+	return entry{}, false, nil
+}
+
+// iters returns iterators over every source of entries, from newest to oldest.
+func (d *db) iters(span state.Span[[]byte]) []iterator {
+	its := []iterator{d.mem.iter(span)}
+	for _, t := range d.tables {
+		its = append(its, t.iter(span))
+	}
+	return its
+}
+
+// removeUnused deletes files which are not referenced by the manifest.
+// They are left behind by crashes during a flush or compaction.
+func (d *db) removeUnused() error {
+	ents, err := posixfs.ReadDir(d.fsx, "")
+	if err != nil {
+		return err
+	}
+	keep := map[string]bool{
+		manifestPath:       true,
+		walPath(d.man.WAL): true,
+	}
+	for _, n := range d.man.Tables {
+		keep[tablePath(n)] = true
+	}
+	for _, ent := range ents {
+		name := ent.Name
+		if keep[name] {
+			continue
+		}
+		if strings.HasSuffix(name, ".tmp") || strings.HasSuffix(name, ".sst") || strings.HasSuffix(name, ".wal") {
+			if err := removeFile(d.fsx, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *db) close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+	return d.closeFiles()
+}
+
+func (d *db) closeFiles() error {
+	var retErr error
+	if d.wal != nil {
+		retErr = d.wal.Close()
+	}
+	for _, t := range d.tables {
+		if err := t.close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}
+	return retErr
+}
+
+// readTx reads from the db. It is only valid while d.mu is held.
+type readTx struct {
+	d *db
+}
+
+func (r readTx) get(key []byte) ([]byte, bool, error) {
+	e, ok, err := r.d.get(key)
+	if err != nil || !ok || e.tombstone {
+		return nil, false, err
+	}
+	return e.value, true, nil
+}
+
+func (r readTx) iter(span state.Span[[]byte]) iterator {
+	return liveIter{newMergeIter(r.d.iters(span), span.IsDesc())}
+}
+
+// writeTx buffers writes to the db in a memtable, until they are committed.
+type writeTx struct {
+	d       *db
+	pending *memtable
+}
+
+func (w *writeTx) get(key []byte) ([]byte, bool, error) {
+	if e, ok := w.pending.get(key); ok {
+		if e.tombstone {
+			return nil, false, nil
+		}
+		return e.value, true, nil
+	}
+	return readTx{w.d}.get(key)
+}
+
+func (w *writeTx) iter(span state.Span[[]byte]) iterator {
+	its := append([]iterator{w.pending.iter(span)}, w.d.iters(span)...)
+	return liveIter{newMergeIter(its, span.IsDesc())}
+}
+
+func (w *writeTx) put(key, value []byte) {
+	w.pending.put(key, value)
+}
+
+func (w *writeTx) delete(key []byte) {
+	w.pending.delete(key)
+}
+
+func applyBatch(m *memtable, batch []byte) error {
+	for len(batch) > 0 {
+		var e entry
+		var ok bool
+		if e, batch, ok = readEntry(batch); !ok {
+			return errCorrupt
+		}
+		m.insert(e)
+	}
+	return nil
+}
+
+func removeFile(fsx posixfs.FS, p string) error {
+	if err := fsx.Remove(p); err != nil && !posixfs.IsErrNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package lsm
+
+import (
+	"bytes"
+)
+
+// iterator produces entries in order of key.
+// The order is ascending, unless the iterator was created from a descending span.
+type iterator interface {
+	// next returns the next entry, or false if there are no more.
+	next() (entry, bool, error)
+}
+
+// mergeIter merges several iterators.
+// When more than one iterator has an entry for the same key, the entry from the earliest iterator is used.
+// Tombstones are included in the output.
+type mergeIter struct {
+	desc    bool
+	its     []iterator
+	heads   []entry
+	valid   []bool
+	started bool
+}
+
+// newMergeIter returns an iterator merging its, which should be ordered from newest to oldest.
+// desc must match the order of all the iterators.
+func newMergeIter(its []iterator, desc bool) *mergeIter {
+	return &mergeIter{
+		desc:  desc,
+		its:   its,
+		heads: make([]entry, len(its)),
+		valid: make([]bool, len(its)),
+	}
+}
+
+func (m *mergeIter) next() (entry, bool, error) {
+	if !m.started {
+		m.started = true
+		for i := range m.its {
+			if err := m.advance(i); err != nil {
+				return entry{}, false, err
+			}
+		}
+	}
+	first := -1
+	for i := range m.its {
+		if !m.valid[i] {
+			continue
+		}
+		if first < 0 || m.before(m.heads[i].key, m.heads[first].key) {
+			first = i
+		}
+	}
+	if first < 0 {
+		return entry{}, false, nil
+	}
+	ret := m.heads[first]
+	for i := range m.its {
+		if m.valid[i] && bytes.Equal(m.heads[i].key, ret.key) {
+			if err := m.advance(i); err != nil {
+				return entry{}, false, err
+			}
+		}
+	}
+	return ret, true, nil
+}
+
+// before returns true if a comes before b in the order of iteration.
+func (m *mergeIter) before(a, b []byte) bool {
+	if m.desc {
+		return bytes.Compare(a, b) > 0
+	}
+	return bytes.Compare(a, b) < 0
+}
+
+func (m *mergeIter) advance(i int) error {
+	e, ok, err := m.its[i].next()
+	if err != nil {
+		return err
+	}
+	m.heads[i], m.valid[i] = e, ok
+	return nil
+}
+
+// liveIter skips tombstones.
+type liveIter struct {
+	it iterator
+}
+
+func (l liveIter) next() (entry, bool, error) {
+	for {
+		e, ok, err := l.it.next()
+		if err != nil || !ok {
+			return e, ok, err
+		}
+		if !e.tombstone {
+			return e, true, nil
+		}
+	}
+}
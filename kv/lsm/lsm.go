@@ -0,0 +1,255 @@
+// Package lsm implements a durable, ordered, kv.Store on a posixfs.FS, using a log-structured merge tree.
+//
+// Each transaction is appended to a write-ahead log and synced before it is applied to an in-memory table.
+// When the memory table grows large enough it is written out as a sorted table file (an SSTable),
+// and when there are too many tables they are merged into one.
+// The set of live files is recorded in a manifest, which is replaced atomically with a rename,
+// so the store can be reopened after a crash at any point.
+package lsm
+
+import (
+	"context"
+
+	"go.brendoncarroll.net/state"
+	"go.brendoncarroll.net/state/kv"
+	"go.brendoncarroll.net/state/posixfs"
+)
+
+const (
+	// DefaultMemtableSize is used if Params.MemtableSize is 0.
+	DefaultMemtableSize = 4 << 20
+	// DefaultMaxTables is used if Params.MaxTables is 0.
+	DefaultMaxTables = 8
+)
+
+type Params[K, V any] struct {
+	// KeyCodec encodes keys. It must preserve order.
+	KeyCodec kv.Codec[K]
+	// ValueCodec encodes values.
+	ValueCodec kv.Codec[V]
+	// MemtableSize is the number of bytes buffered in memory before they are written to a table.
+	MemtableSize int
+	// MaxTables is the number of tables which can exist before they are compacted into one.
+	MaxTables int
+	// NoSync skips syncing the log after each transaction.
+	// Transactions which have returned may be lost in a crash, but the store will still be consistent.
+	NoSync bool
+	// ReadOnly opens an existing store without changing any of its files.
+	// Open fails if there is no store, and writes return an error.
+	ReadOnly bool
+}
+
+var _ kv.StoreTx[int, int] = &Store[int, int]{}
+var _ kv.Store[int, int] = &Store[int, int]{}
+var _ kv.EntryLister[int, int] = &Store[int, int]{}
+
+// Store is a durable kv.Store and kv.StoreTx.
+// Readers share a lock, and writers hold it exclusively, including while flushing and compacting.
+type Store[K, V any] struct {
+	db *db
+	kc kv.Codec[K]
+	vc kv.Codec[V]
+}
+
+// Open opens the store in fsx, creating it if it does not exist.
+// fsx should be dedicated to the store.
+// Writes in the log which were torn by a crash are discarded.
+func Open[K, V any](ctx context.Context, fsx posixfs.FS, params Params[K, V]) (*Store[K, V], error) {
+	if params.MemtableSize <= 0 {
+		params.MemtableSize = DefaultMemtableSize
+	}
+	if params.MaxTables <= 0 {
+		params.MaxTables = DefaultMaxTables
+	}
+	d, err := openDB(ctx, fsx, dbParams{
+		memtableSize: params.MemtableSize,
+		maxTables:    params.MaxTables,
+		noSync:       params.NoSync,
+		readOnly:     params.ReadOnly,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Store[K, V]{db: d, kc: params.KeyCodec, vc: params.ValueCodec}, nil
+}
+
+func (s *Store[K, V]) Put(ctx context.Context, k K, v V) error {
+	return s.Modify(ctx, func(tx kv.Store[K, V]) error {
+		return tx.Put(ctx, k, v)
+	})
+}
+
+func (s *Store[K, V]) Delete(ctx context.Context, k K) error {
+	return s.Modify(ctx, func(tx kv.Store[K, V]) error {
+		return tx.Delete(ctx, k)
+	})
+}
+
+// DeleteSpan removes the keys in span in a single transaction.
+func (s *Store[K, V]) DeleteSpan(ctx context.Context, span state.Span[K]) (n int, err error) {
+	err = s.Modify(ctx, func(tx kv.Store[K, V]) error {
+		n, err = kv.DeleteSpan[K](ctx, tx, span)
+		return err
+	})
+	return n, err
+}
+
+func (s *Store[K, V]) Get(ctx context.Context, k K, dst *V) error {
+	return s.View(ctx, func(tx kv.ReadOnlyStore[K, V]) error {
+		return tx.Get(ctx, k, dst)
+	})
+}
+
+func (s *Store[K, V]) Exists(ctx context.Context, k K) (bool, error) {
+	return kv.ExistsUsingList[K](ctx, s, k)
+}
+
+func (s *Store[K, V]) List(ctx context.Context, span state.Span[K], buf []K) (n int, err error) {
+	err = s.View(ctx, func(tx kv.ReadOnlyStore[K, V]) error {
+		n, err = tx.List(ctx, span, buf)
+		return err
+	})
+	return n, err
+}
+
+// ListEntries reads the keys and values in span from a single view of the store.
+func (s *Store[K, V]) ListEntries(ctx context.Context, span state.Span[K], buf []kv.Entry[K, V]) (n int, err error) {
+	err = s.View(ctx, func(tx kv.ReadOnlyStore[K, V]) error {
+		n, err = kv.ListEntries(ctx, tx, span, buf)
+		return err
+	})
+	return n, err
+}
+
+// View calls fn with a read only view of the store.
+// Writers are blocked until fn returns.
+func (s *Store[K, V]) View(ctx context.Context, fn func(kv.ReadOnlyStore[K, V]) error) error {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	if s.db.closed {
+		return errClosed
+	}
+	return fn(&view[K, V]{s: s, r: readTx{s.db}})
+}
+
+// Modify calls fn with a mutable view of the store.
+// If fn returns nil, the changes are written to the log and applied, otherwise they are discarded.
+func (s *Store[K, V]) Modify(ctx context.Context, fn func(kv.Store[K, V]) error) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if err := s.db.checkWritable(); err != nil {
+		return err
+	}
+	tx := &writeTx{d: s.db, pending: newMemtable()}
+	if err := fn(&view[K, V]{s: s, r: tx, w: tx}); err != nil {
+		return err
+	}
+	return s.db.commit(tx.pending)
+}
+
+// Compact writes any buffered data to a table, and merges all the tables into one.
+func (s *Store[K, V]) Compact(ctx context.Context) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if err := s.db.checkWritable(); err != nil {
+		return err
+	}
+	if err := s.db.rotate(); err != nil {
+		return err
+	}
+	return s.db.compact()
+}
+
+// Close releases the files held open by the store.
+func (s *Store[K, V]) Close() error {
+	return s.db.close()
+}
+
+type byteReader interface {
+	get(key []byte) ([]byte, bool, error)
+	iter(span state.Span[[]byte]) iterator
+}
+
+// view implements kv.Store using a byteReader, and a writeTx if it is mutable.
+type view[K, V any] struct {
+	s *Store[K, V]
+	r byteReader
+	w *writeTx
+}
+
+func (v *view[K, V]) Get(ctx context.Context, k K, dst *V) error {
+	data, ok, err := v.r.get(v.s.kc.Encode(nil, k))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return state.ErrNotFound[K]{Key: k}
+	}
+	x, err := v.s.vc.Decode(data)
+	if err != nil {
+		return err
+	}
+	*dst = x
+	return nil
+}
+
+func (v *view[K, V]) List(ctx context.Context, span state.Span[K], buf []K) (int, error) {
+	it := v.r.iter(kv.EncodeSpan(span, v.s.kc))
+	var n int
+	for n < len(buf) {
+		e, ok, err := it.next()
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+		k, err := v.s.kc.Decode(e.key)
+		if err != nil {
+			return 0, err
+		}
+		buf[n] = k
+		n++
+	}
+	return n, nil
+}
+
+func (v *view[K, V]) ListEntries(ctx context.Context, span state.Span[K], buf []kv.Entry[K, V]) (int, error) {
+	it := v.r.iter(kv.EncodeSpan(span, v.s.kc))
+	var n int
+	for n < len(buf) {
+		e, ok, err := it.next()
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+		k, err := v.s.kc.Decode(e.key)
+		if err != nil {
+			return 0, err
+		}
+		x, err := v.s.vc.Decode(e.value)
+		if err != nil {
+			return 0, err
+		}
+		buf[n] = kv.Entry[K, V]{Key: k, Value: x}
+		n++
+	}
+	return n, nil
+}
+
+func (v *view[K, V]) Exists(ctx context.Context, k K) (bool, error) {
+	_, ok, err := v.r.get(v.s.kc.Encode(nil, k))
+	return ok, err
+}
+
+func (v *view[K, V]) Put(ctx context.Context, k K, x V) error {
+	v.w.put(v.s.kc.Encode(nil, k), v.s.vc.Encode(nil, x))
+	return nil
+}
+
+func (v *view[K, V]) Delete(ctx context.Context, k K) error {
+	v.w.delete(v.s.kc.Encode(nil, k))
+	return nil
+}
@@ -0,0 +1,247 @@
+package lsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.brendoncarroll.net/state"
+	"go.brendoncarroll.net/state/kv"
+	"go.brendoncarroll.net/state/kv/kvtest"
+	"go.brendoncarroll.net/state/posixfs"
+)
+
+func TestConformance(t *testing.T) {
+	kvtest.TestStoreTx(t, func(t testing.TB) kvtest.StoreTx {
+		return newTestStore(t, posixfs.NewTestFS(t), 0)
+	})
+}
+
+func TestConformanceSmallMemtable(t *testing.T) {
+	// flushes and compactions happen every few writes.
+	kvtest.TestStoreTx(t, func(t testing.TB) kvtest.StoreTx {
+		return newTestStore(t, posixfs.NewTestFS(t), 64)
+	})
+}
+
+func TestReopen(t *testing.T) {
+	ctx := context.Background()
+	for _, memtableSize := range []int{0, 100} {
+		t.Run(fmt.Sprint("memtable=", memtableSize), func(t *testing.T) {
+			fsx := posixfs.NewTestFS(t)
+			s := newTestStore(t, fsx, memtableSize)
+			model := map[string]string{}
+			for i := 0; i < 300; i++ {
+				k := fmt.Sprintf("%03d", i%100)
+				if i%7 == 0 {
+					require.NoError(t, s.Delete(ctx, []byte(k)))
+					delete(model, k)
+				} else {
+					v := fmt.Sprint(i)
+					require.NoError(t, s.Put(ctx, []byte(k), []byte(v)))
+					model[k] = v
+				}
+			}
+			require.NoError(t, s.Close())
+			_, err := kv.Get[[]byte, []byte](ctx, s, []byte("000"))
+			require.ErrorIs(t, err, errClosed)
+
+			s = newTestStore(t, fsx, memtableSize)
+			checkModel(t, s, model)
+			require.NoError(t, s.Compact(ctx))
+			checkModel(t, s, model)
+			require.NoError(t, s.Close())
+
+			s = newTestStore(t, fsx, memtableSize)
+			checkModel(t, s, model)
+			require.LessOrEqual(t, len(s.db.tables), 1)
+		})
+	}
+}
+
+func TestTornLog(t *testing.T) {
+	ctx := context.Background()
+	fsx := posixfs.NewTestFS(t)
+	s := newTestStore(t, fsx, 0)
+	require.NoError(t, s.Put(ctx, []byte("a"), []byte("1")))
+	walNum := s.db.man.WAL
+	require.NoError(t, s.Put(ctx, []byte("b"), []byte("2")))
+	require.NoError(t, s.Close())
+
+	// chop the end off the last record, as if the process crashed while writing it.
+	data, err := posixfs.ReadFile(ctx, fsx, walPath(walNum))
+	require.NoError(t, err)
+	require.NoError(t, writeFileAtomic(fsx, walPath(walNum), data[:len(data)-1]))
+
+	s = newTestStore(t, fsx, 0)
+	checkModel(t, s, map[string]string{"a": "1"})
+	// the store is still writable, and the new writes survive another reopen.
+	require.NoError(t, s.Put(ctx, []byte("c"), []byte("3")))
+	require.NoError(t, s.Close())
+	s = newTestStore(t, fsx, 0)
+	checkModel(t, s, map[string]string{"a": "1", "c": "3"})
+}
+
+func TestRemoveUnused(t *testing.T) {
+	ctx := context.Background()
+	fsx := posixfs.NewTestFS(t)
+	s := newTestStore(t, fsx, 0)
+	require.NoError(t, s.Put(ctx, []byte("a"), []byte("1")))
+	require.NoError(t, s.Close())
+	// leftovers from a crash during a flush.
+	for _, p := range []string{tablePath(1000), tablePath(1001) + ".tmp", manifestPath + ".tmp"} {
+		require.NoError(t, writeFileAtomic(fsx, p, []byte("garbage")))
+	}
+
+	s = newTestStore(t, fsx, 0)
+	checkModel(t, s, map[string]string{"a": "1"})
+	ents, err := posixfs.ReadDir(fsx, "")
+	require.NoError(t, err)
+	var names []string
+	for _, ent := range ents {
+		names = append(names, ent.Name)
+	}
+	require.ElementsMatch(t, []string{manifestPath, walPath(s.db.man.WAL), tablePath(s.db.man.Tables[0])}, names)
+}
+
+func TestOrphanedFiles(t *testing.T) {
+	ctx := context.Background()
+	fsx := posixfs.NewTestFS(t)
+	s := newTestStore(t, fsx, 0)
+	require.NoError(t, s.Put(ctx, []byte("a"), []byte("1")))
+	require.NoError(t, s.Close())
+	// files created by a crash during rotate, before the manifest recorded them.
+	next := s.db.man.NextFile
+	for _, p := range []string{walPath(next), tablePath(next + 1)} {
+		require.NoError(t, writeFileAtomic(fsx, p, []byte("garbage")))
+	}
+
+	s = newTestStore(t, fsx, 0)
+	checkModel(t, s, map[string]string{"a": "1"})
+	require.NoError(t, s.Put(ctx, []byte("b"), []byte("2")))
+	require.NoError(t, s.Close())
+	s = newTestStore(t, fsx, 0)
+	checkModel(t, s, map[string]string{"a": "1", "b": "2"})
+	ents, err := posixfs.ReadDir(fsx, "")
+	require.NoError(t, err)
+	var names []string
+	for _, ent := range ents {
+		names = append(names, ent.Name)
+	}
+	want := []string{manifestPath, walPath(s.db.man.WAL)}
+	for _, n := range s.db.man.Tables {
+		want = append(want, tablePath(n))
+	}
+	require.ElementsMatch(t, want, names)
+}
+
+func TestFailedFlush(t *testing.T) {
+	ctx := context.Background()
+	fsx := &failFS{FS: posixfs.NewTestFS(t)}
+	s := newTestStore(t, fsx, 1)
+	require.NoError(t, s.Put(ctx, []byte("a"), []byte("1")))
+
+	// the transaction is in the log before the flush fails, so it has committed.
+	fsx.failTables = true
+	require.NoError(t, s.Put(ctx, []byte("b"), []byte("2")))
+	checkModel(t, s, map[string]string{"a": "1", "b": "2"})
+	// the failure is reported by the next write.
+	require.Error(t, s.Put(ctx, []byte("c"), []byte("3")))
+
+	require.NoError(t, s.Close())
+	fsx.failTables = false
+	s = newTestStore(t, fsx, 1)
+	checkModel(t, s, map[string]string{"a": "1", "b": "2"})
+}
+
+func TestReadOnly(t *testing.T) {
+	ctx := context.Background()
+	fsx := posixfs.NewTestFS(t)
+	params := Params[[]byte, []byte]{KeyCodec: kv.BytesCodec{}, ValueCodec: kv.BytesCodec{}, ReadOnly: true}
+	_, err := Open(ctx, fsx, params)
+	require.True(t, posixfs.IsErrNotExist(err))
+	require.Empty(t, listDir(t, fsx))
+
+	s := newTestStore(t, fsx, 0)
+	require.NoError(t, s.Put(ctx, []byte("a"), []byte("1")))
+	require.NoError(t, s.Close())
+	before := listDir(t, fsx)
+
+	ro, err := Open(ctx, fsx, params)
+	require.NoError(t, err)
+	checkModel(t, ro, map[string]string{"a": "1"})
+	require.ErrorIs(t, ro.Put(ctx, []byte("b"), []byte("2")), errReadOnly)
+	require.ErrorIs(t, ro.Compact(ctx), errReadOnly)
+	require.NoError(t, ro.Close())
+	require.Equal(t, before, listDir(t, fsx))
+}
+
+func TestCompactDropsTombstones(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t, posixfs.NewTestFS(t), 0)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, s.Put(ctx, []byte(fmt.Sprint(i)), nil))
+	}
+	require.NoError(t, s.Compact(ctx))
+	for i := 0; i < 10; i++ {
+		require.NoError(t, s.Delete(ctx, []byte(fmt.Sprint(i))))
+	}
+	require.NoError(t, s.Compact(ctx))
+	require.Empty(t, s.db.tables)
+	checkModel(t, s, map[string]string{})
+}
+
+func newTestStore(t testing.TB, fsx posixfs.FS, memtableSize int) *Store[[]byte, []byte] {
+	s, err := Open[[]byte, []byte](context.Background(), fsx, Params[[]byte, []byte]{
+		KeyCodec:     kv.BytesCodec{},
+		ValueCodec:   kv.BytesCodec{},
+		MemtableSize: memtableSize,
+		MaxTables:    2,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func checkModel(t testing.TB, s *Store[[]byte, []byte], model map[string]string) {
+	ctx := context.Background()
+	var count int
+	err := kv.ForEach[[]byte](ctx, s, state.TotalSpan[[]byte](), func(k []byte) error {
+		count++
+		v, err := kv.Get[[]byte, []byte](ctx, s, k)
+		if err != nil {
+			return err
+		}
+		require.Equal(t, model[string(k)], string(v), "key=%s", k)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, len(model), count)
+}
+
+// failFS fails to create tables while failTables is set.
+type failFS struct {
+	posixfs.FS
+	failTables bool
+}
+
+func (fs *failFS) OpenFile(p string, flag int, perm posixfs.FileMode) (posixfs.File, error) {
+	if fs.failTables && flag&posixfs.O_CREATE != 0 && strings.Contains(p, ".sst") {
+		return nil, errors.New("injected failure")
+	}
+	return fs.FS.OpenFile(p, flag, perm)
+}
+
+func listDir(t testing.TB, fsx posixfs.FS) []string {
+	ents, err := posixfs.ReadDir(fsx, "")
+	require.NoError(t, err)
+	var names []string
+	for _, ent := range ents {
+		names = append(names, ent.Name)
+	}
+	return names
+}
@@ -0,0 +1,102 @@
+package lsm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"go.brendoncarroll.net/state/posixfs"
+)
+
+const (
+	manifestPath    = "MANIFEST"
+	manifestVersion = 1
+)
+
+// manifest lists the files which make up the store.
+// It is replaced atomically by writing a new file and renaming it over the old one.
+type manifest struct {
+	Version int `json:"version"`
+	// NextFile is the number which will be used for the next file created.
+	NextFile uint64 `json:"next_file"`
+	// WAL is the number of the log holding writes which are not in a table.
+	WAL uint64 `json:"wal"`
+	// Tables are the numbers of the SSTables, from newest to oldest.
+	Tables []uint64 `json:"tables"`
+}
+
+func (m *manifest) allocFile() uint64 {
+	n := m.NextFile
+	m.NextFile++
+	return n
+}
+
+func loadManifest(ctx context.Context, fsx posixfs.FS) (*manifest, error) {
+	data, err := posixfs.ReadFile(ctx, fsx, manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("lsm: parsing manifest: %w", err)
+	}
+	if m.Version != manifestVersion {
+		return nil, fmt.Errorf("lsm: unsupported manifest version %d", m.Version)
+	}
+	return &m, nil
+}
+
+func saveManifest(fsx posixfs.FS, m *manifest) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(fsx, manifestPath, data)
+}
+
+// writeFileAtomic writes data to a temporary file, syncs it, and then renames it to p.
+// The directory is synced after the rename, so the new file survives a crash.
+func writeFileAtomic(fsx posixfs.FS, p string, data []byte) error {
+	tmpPath := p + ".tmp"
+	f, err := fsx.OpenFile(tmpPath, posixfs.O_WRONLY|posixfs.O_CREATE|posixfs.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := fsx.Rename(tmpPath, p); err != nil {
+		return err
+	}
+	return syncDir(fsx, path.Dir(p))
+}
+
+// syncDir syncs the directory at p, which makes changes to its entries durable.
+func syncDir(fsx posixfs.FS, p string) error {
+	f, err := fsx.OpenFile(p, posixfs.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func walPath(n uint64) string {
+	return fmt.Sprintf("%06d.wal", n)
+}
+
+func tablePath(n uint64) string {
+	return fmt.Sprintf("%06d.sst", n)
+}
@@ -0,0 +1,135 @@
+package lsm
+
+import (
+	"bytes"
+
+	"github.com/google/btree"
+
+	"go.brendoncarroll.net/state"
+)
+
+// entry is a key and either a value, or a tombstone marking the key as deleted.
+type entry struct {
+	key       []byte
+	value     []byte
+	tombstone bool
+}
+
+// memtable holds recent writes in memory, including deletes as tombstones.
+type memtable struct {
+	tree *btree.BTreeG[entry]
+	// size is the approximate number of bytes held.
+	size int
+}
+
+func newMemtable() *memtable {
+	return &memtable{
+		tree: btree.NewG[entry](8, func(a, b entry) bool {
+			return bytes.Compare(a.key, b.key) < 0
+		}),
+	}
+}
+
+// put adds a copy of k and v to the memtable
+func (m *memtable) put(k, v []byte) {
+	m.insert(entry{
+		key:   append([]byte{}, k...),
+		value: append([]byte{}, v...),
+	})
+}
+
+// delete adds a tombstone for k to the memtable.
+func (m *memtable) delete(k []byte) {
+	m.insert(entry{key: append([]byte{}, k...), tombstone: true})
+}
+
+func (m *memtable) insert(e entry) {
+	if prev, replaced := m.tree.ReplaceOrInsert(e); replaced {
+		m.size -= len(prev.key) + len(prev.value)
+	}
+	m.size += len(e.key) + len(e.value)
+}
+
+func (m *memtable) get(k []byte) (entry, bool) {
+	return m.tree.Get(entry{key: k})
+}
+
+func (m *memtable) len() int {
+	return m.tree.Len()
+}
+
+func (m *memtable) iter(span state.Span[[]byte]) iterator {
+	return &memIter{m: m, span: span}
+}
+
+// memIter iterates over a memtable in chunks, so the btree does not need to be paused mid iteration.
+type memIter struct {
+	m    *memtable
+	span state.Span[[]byte]
+	buf  []entry
+	pos  int
+	last []byte
+	done bool
+}
+
+func (it *memIter) next() (entry, bool, error) {
+	if it.pos == len(it.buf) {
+		if it.done {
+			return entry{}, false, nil
+		}
+		it.fill()
+		if len(it.buf) == 0 {
+			return entry{}, false, nil
+		}
+	}
+	e := it.buf[it.pos]
+	it.pos++
+	return e, true, nil
+}
+
+func (it *memIter) fill() {
+	const chunkSize = 64
+	it.buf, it.pos = it.buf[:0], 0
+	desc := it.span.IsDesc()
+	fn := func(e entry) bool {
+		if it.last != nil {
+			c := bytes.Compare(e.key, it.last)
+			if (!desc && c <= 0) || (desc && c >= 0) {
+				return true
+			}
+		}
+		c := it.span.Compare(e.key, bytes.Compare)
+		if desc {
+			c = -c
+		}
+		if c > 0 {
+			return true
+		} else if c < 0 {
+			it.done = true
+			return false
+		}
+		it.buf = append(it.buf, e)
+		return len(it.buf) < chunkSize
+	}
+	if desc {
+		if it.last != nil {
+			it.m.tree.DescendLessOrEqual(entry{key: it.last}, fn)
+		} else if upper, ok := it.span.UpperBound(); ok {
+			it.m.tree.DescendLessOrEqual(entry{key: upper}, fn)
+		} else {
+			it.m.tree.Descend(fn)
+		}
+	} else if it.last != nil {
+		it.m.tree.AscendGreaterOrEqual(entry{key: it.last}, fn)
+	} else if lower, ok := it.span.LowerBound(); ok {
+		it.m.tree.AscendGreaterOrEqual(entry{key: lower}, fn)
+	} else {
+		it.m.tree.Ascend(fn)
+	}
+	if len(it.buf) < chunkSize {
+		it.done = true
+	}
+	if len(it.buf) > 0 {
+		it.last = it.buf[len(it.buf)-1].key
+	}
+}
@@ -0,0 +1,413 @@
+package lsm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+	"sync"
+
+	"go.brendoncarroll.net/state"
+	"go.brendoncarroll.net/state/posixfs"
+)
+
+// An SSTable is an immutable file of entries sorted by key.
+//
+//	block* | index | footer
+//
+// Each block holds entries, followed by the crc32 of the entries.
+// Each entry is:
+//
+//	flags byte | uvarint(len(key)) | key | uvarint(len(value)) | value
+//
+// The index has an item for each block:
+//
+//	uvarint(len(firstKey)) | firstKey | uvarint(offset) | uvarint(length)
+//
+// The footer is:
+//
+//	indexOffset uint64 | indexLength uint32 | crc32(index) uint32 | count uint64 | sstMagic
+//
+// with integers in big endian.
+const (
+	sstMagic   = "KVLSMSST"
+	footerSize = 8 + 4 + 4 + 8 + len(sstMagic)
+	blockSize  = 4096
+)
+
+const flagTombstone = 1
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+type blockHandle struct {
+	firstKey []byte
+	offset   uint64
+	length   uint64
+}
+
+type sstable struct {
+	num    uint64
+	blocks []blockHandle
+	count  uint64
+
+	mu sync.Mutex
+	f  posixfs.File
+}
+
+// writeSSTable writes the entries from it to a new SSTable at p.
+// The file is written to a temporary path, synced, and then renamed to p.
+// If dropTombstones is true, tombstones are not written.
+// It returns the number of entries written, if it is 0, no file is created.
+func writeSSTable(fsx posixfs.FS, p string, it iterator, dropTombstones bool) (count uint64, retErr error) {
+	tmpPath := p + ".tmp"
+	f, err := fsx.OpenFile(tmpPath, posixfs.O_WRONLY|posixfs.O_CREATE|posixfs.O_TRUNC, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+		if retErr != nil || count == 0 {
+			fsx.Remove(tmpPath)
+		}
+	}()
+	bw := bufio.NewWriter(f)
+	var offset uint64
+	var index []byte
+	var block []byte
+	var firstKey []byte
+	flushBlock := func() error {
+		if len(block) == 0 {
+			return nil
+		}
+		block = appendUint32(block, crc32.Checksum(block, crcTable))
+		if _, err := bw.Write(block); err != nil {
+			return err
+		}
+		index = appendBytes(index, firstKey)
+		index = appendUvarint(index, offset)
+		index = appendUvarint(index, uint64(len(block)))
+		offset += uint64(len(block))
+		block = block[:0]
+		return nil
+	}
+	for {
+		e, ok, err := it.next()
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+		if dropTombstones && e.tombstone {
+			continue
+		}
+		if len(block) == 0 {
+			firstKey = append(firstKey[:0], e.key...)
+		}
+		block = appendEntry(block, e)
+		count++
+		if len(block) >= blockSize {
+			if err := flushBlock(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if count == 0 {
+		return 0, nil
+	}
+	if err := flushBlock(); err != nil {
+		return 0, err
+	}
+	if _, err := bw.Write(index); err != nil {
+		return 0, err
+	}
+	var footer []byte
+	footer = appendUint64(footer, offset)
+	footer = appendUint32(footer, uint32(len(index)))
+	footer = appendUint32(footer, crc32.Checksum(index, crcTable))
+	footer = appendUint64(footer, count)
+	footer = append(footer, sstMagic...)
+	if _, err := bw.Write(footer); err != nil {
+		return 0, err
+	}
+	if err := bw.Flush(); err != nil {
+		return 0, err
+	}
+	if err := f.Sync(); err != nil {
+		return 0, err
+	}
+	err = f.Close()
+	f = nil
+	if err != nil {
+		return 0, err
+	}
+	return count, fsx.Rename(tmpPath, p)
+}
+
+func openSSTable(fsx posixfs.FS, p string, num uint64) (*sstable, error) {
+	f, err := fsx.OpenFile(p, posixfs.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	t, err := readSSTable(f, num)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("opening sstable %s: %w", p, err)
+	}
+	return t, nil
+}
+
+func readSSTable(f posixfs.File, num uint64) (*sstable, error) {
+	finfo, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if finfo.Size() < int64(footerSize) {
+		return nil, errCorrupt
+	}
+	footer := make([]byte, footerSize)
+	if err := readAt(f, footer, finfo.Size()-int64(footerSize)); err != nil {
+		return nil, err
+	}
+	if string(footer[24:]) != sstMagic {
+		return nil, errCorrupt
+	}
+	indexOffset := binary.BigEndian.Uint64(footer[0:8])
+	indexLen := binary.BigEndian.Uint32(footer[8:12])
+	indexSum := binary.BigEndian.Uint32(footer[12:16])
+	count := binary.BigEndian.Uint64(footer[16:24])
+	if indexOffset+uint64(indexLen)+uint64(footerSize) != uint64(finfo.Size()) {
+		return nil, errCorrupt
+	}
+	index := make([]byte, indexLen)
+	if err := readAt(f, index, int64(indexOffset)); err != nil {
+		return nil, err
+	}
+	if crc32.Checksum(index, crcTable) != indexSum {
+		return nil, errCorrupt
+	}
+	var blocks []blockHandle
+	for len(index) > 0 {
+		var bh blockHandle
+		var ok bool
+		if bh.firstKey, index, ok = readBytes(index); !ok {
+			return nil, errCorrupt
+		}
+		if bh.offset, index, ok = readUvarint(index); !ok {
+			return nil, errCorrupt
+		}
+		if bh.length, index, ok = readUvarint(index); !ok {
+			return nil, errCorrupt
+		}
+		blocks = append(blocks, bh)
+	}
+	return &sstable{num: num, f: f, blocks: blocks, count: count}, nil
+}
+
+func (t *sstable) get(key []byte) (entry, bool, error) {
+	i := t.findBlock(key)
+	if i < 0 {
+		return entry{}, false, nil
+	}
+	ents, err := t.readBlock(i)
+	if err != nil {
+		return entry{}, false, err
+	}
+	j := sort.Search(len(ents), func(j int) bool {
+		return bytes.Compare(ents[j].key, key) >= 0
+	})
+	if j < len(ents) && bytes.Equal(ents[j].key, key) {
+		return ents[j], true, nil
+	}
+	return entry{}, false, nil
+}
+
+func (t *sstable) iter(span state.Span[[]byte]) iterator {
+	it := &sstIter{t: t, span: span}
+	if span.IsDesc() {
+		it.block = len(t.blocks) - 1
+		if upper, ok := span.UpperBound(); ok {
+			it.block = t.findBlock(upper)
+		}
+	} else if lower, ok := span.LowerBound(); ok {
+		if i := t.findBlock(lower); i > 0 {
+			it.block = i
+		}
+	}
+	return it
+}
+
+// findBlock returns the index of the block which would contain key, or -1 if key is before every block.
+func (t *sstable) findBlock(key []byte) int {
+	i := sort.Search(len(t.blocks), func(i int) bool {
+		return bytes.Compare(t.blocks[i].firstKey, key) > 0
+	})
+	return i - 1
+}
+
+func (t *sstable) readBlock(i int) ([]entry, error) {
+	bh := t.blocks[i]
+	data := make([]byte, bh.length)
+	t.mu.Lock()
+	err := readAt(t.f, data, int64(bh.offset))
+	t.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 4 {
+		return nil, errCorrupt
+	}
+	body, sum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.Checksum(body, crcTable) != sum {
+		return nil, errCorrupt
+	}
+	var ents []entry
+	for len(body) > 0 {
+		var e entry
+		var ok bool
+		if e, body, ok = readEntry(body); !ok {
+			return nil, errCorrupt
+		}
+		ents = append(ents, e)
+	}
+	return ents, nil
+}
+
+func (t *sstable) close() error {
+	return t.f.Close()
+}
+
+// sstIter reads the blocks of an sstable in order.
+// For descending spans the blocks are read from last to first, and the entries in each block are reversed.
+type sstIter struct {
+	t     *sstable
+	span  state.Span[[]byte]
+	block int
+	ents  []entry
+	pos   int
+	done  bool
+}
+
+func (it *sstIter) next() (entry, bool, error) {
+	desc := it.span.IsDesc()
+	for !it.done {
+		if it.pos == len(it.ents) {
+			if it.block < 0 || it.block >= len(it.t.blocks) {
+				it.done = true
+				break
+			}
+			ents, err := it.t.readBlock(it.block)
+			if err != nil {
+				return entry{}, false, err
+			}
+			if desc {
+				for i, j := 0, len(ents)-1; i < j; i, j = i+1, j-1 {
+					ents[i], ents[j] = ents[j], ents[i]
+				}
+				it.block--
+			} else {
+				it.block++
+			}
+			it.ents, it.pos = ents, 0
+			continue
+		}
+		e := it.ents[it.pos]
+		it.pos++
+		c := it.span.Compare(e.key, bytes.Compare)
+		if desc {
+			c = -c
+		}
+		if c > 0 {
+			continue
+		} else if c < 0 {
+			it.done = true
+			break
+		}
+		return e, true, nil
+	}
+	return entry{}, false, nil
+}
+
+var errCorrupt = errors.New("lsm: corrupt data")
+
+func appendEntry(out []byte, e entry) []byte {
+	var flags byte
+	if e.tombstone {
+		flags |= flagTombstone
+	}
+	out = append(out, flags)
+	out = appendBytes(out, e.key)
+	return appendBytes(out, e.value)
+}
+
+// readEntry parses an entry from the front of data, and returns the rest.
+// The entry does not alias data.
+func readEntry(data []byte) (entry, []byte, bool) {
+	if len(data) < 1 {
+		return entry{}, nil, false
+	}
+	e := entry{tombstone: data[0]&flagTombstone != 0}
+	data = data[1:]
+	var ok bool
+	if e.key, data, ok = readBytes(data); !ok {
+		return entry{}, nil, false
+	}
+	if e.value, data, ok = readBytes(data); !ok {
+		return entry{}, nil, false
+	}
+	return e, data, true
+}
+
+func appendBytes(out []byte, x []byte) []byte {
+	out = appendUvarint(out, uint64(len(x)))
+	return append(out, x...)
+}
+
+func readBytes(data []byte) ([]byte, []byte, bool) {
+	n, data, ok := readUvarint(data)
+	if !ok || uint64(len(data)) < n {
+		return nil, nil, false
+	}
+	return append([]byte{}, data[:n]...), data[n:], true
+}
+
+func readUvarint(data []byte) (uint64, []byte, bool) {
+	x, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, nil, false
+	}
+	return x, data[n:], true
+}
+
+// readAt fills buf with data from f starting at offset.
+func readAt(f posixfs.File, buf []byte, offset int64) error {
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := io.ReadFull(f, buf)
+	return err
+}
+
+func appendUvarint(out []byte, x uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], x)
+	return append(out, buf[:n]...)
+}
+
+func appendUint32(out []byte, x uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], x)
+	return append(out, buf[:]...)
+}
+
+func appendUint64(out []byte, x uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], x)
+	return append(out, buf[:]...)
+}
@@ -13,6 +13,8 @@ type MemStore[K, V any] struct {
 	cmp  func(a, b K) int
 	mu   sync.RWMutex
 	tree btree.BTreeG[Entry[K, V]]
+	// log is set for stores created with OpenMemStore.
+	log *memLog[K, V]
 }
 
 func NewMemStore[K, V any](cmp func(a, b K) int) *MemStore[K, V] {
@@ -36,6 +38,15 @@ func (s *MemStore[K, V]) Delete(ctx context.Context, k K) error {
 	})
 }
 
+// DeleteSpan removes the keys in span in a single transaction.
+func (s *MemStore[K, V]) DeleteSpan(ctx context.Context, span state.Span[K]) (n int, err error) {
+	err = s.Modify(ctx, func(tx Store[K, V]) error {
+		n, err = DeleteSpan[K](ctx, tx, span)
+		return err
+	})
+	return n, err
+}
+
 func (s *MemStore[K, V]) Exists(ctx context.Context, k K) (bool, error) {
 	return ExistsUsingList[K](ctx, s, k)
 }
@@ -54,6 +65,15 @@ func (s *MemStore[K, V]) List(ctx context.Context, span state.Span[K], buf []K)
 	return n, err
 }
 
+// ListEntries reads the keys and values in span from a single consistent view of the store.
+func (s *MemStore[K, V]) ListEntries(ctx context.Context, span state.Span[K], buf []Entry[K, V]) (n int, err error) {
+	err = s.View(ctx, func(s ReadOnlyStore[K, V]) error {
+		n, err = ListEntries(ctx, s, span, buf)
+		return err
+	})
+	return n, err
+}
+
 func (s *MemStore[K, V]) Len() (count int) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -81,12 +101,22 @@ func (s *MemStore[K, V]) Modify(ctx context.Context, fn func(Store[K, V]) error)
 	if err := fn(tx); err != nil {
 		return err
 	}
+	if s.log != nil && (len(tx.puts) > 0 || len(tx.deletes) > 0) {
+		if err := s.log.append(tx.puts, tx.deletes); err != nil {
+			return err
+		}
+	}
 	for _, e := range tx.puts {
 		s.tree.ReplaceOrInsert(e)
 	}
 	for _, e := range tx.deletes {
 		s.tree.Delete(Entry[K, V]{Key: e.Key})
 	}
+	if s.log != nil && s.log.commits >= s.log.p.CheckpointEvery {
+		// The transaction is already committed, so a failed checkpoint is not reported here.
+		// It will be attempted again after the next commit.
+		s.log.checkpoint(&s.tree)
+	}
 	return nil
 }
 
@@ -130,25 +160,129 @@ func (s *memTxStore[K, V]) Exists(ctx context.Context, k K) (bool, error) {
 }
 
 func (s *memTxStore[K, V]) List(ctx context.Context, span state.Span[K], buf []K) (n int, _ error) {
-	// TODO: implement this for transactions
-	if s.puts != nil || s.deletes != nil {
-		panic("List not yet implemented for write transactions")
+	if len(buf) == 0 {
+		return 0, nil
 	}
-	s.read.Ascend(func(e Entry[K, V]) bool {
-		if n == len(buf) {
-			return false
+	s.iterate(span, func(e Entry[K, V]) bool {
+		buf[n] = e.Key
+		n++
+		return n < len(buf)
+	})
+	return n, nil
+}
+
+func (s *memTxStore[K, V]) ListEntries(ctx context.Context, span state.Span[K], buf []Entry[K, V]) (n int, _ error) {
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	s.iterate(span, func(e Entry[K, V]) bool {
+		buf[n] = e
+		n++
+		return n < len(buf)
+	})
+	return n, nil
+}
+
+// iterate calls fn with the entries in span, in the order of span, until fn returns false.
+// It merges the pending puts and deletes with the entries in the tree.
+// Descending spans are merged in the same way, walking both in reverse.
+func (s *memTxStore[K, V]) iterate(span state.Span[K], fn func(Entry[K, V]) bool) {
+	desc := span.IsDesc()
+	// order compares keys in the order of iteration.
+	order := func(a, b K) int {
+		if desc {
+			return s.cmp(b, a)
 		}
+		return s.cmp(a, b)
+	}
+	// emit calls fn with e if it is in the span, and returns false if no more entries should be emitted.
+	emit := func(e Entry[K, V]) bool {
 		c := span.Compare(e.Key, s.cmp)
+		if desc {
+			c = -c
+		}
 		if c > 0 {
 			return true
 		} else if c < 0 {
 			return false
 		}
-		buf[n] = e.Key
-		n++
+		return fn(e)
+	}
+	puts := s.putsFrom(span)
+	done := false
+	s.walkFrom(span, func(e Entry[K, V]) bool {
+		for len(puts) > 0 && order(puts[0].Key, e.Key) < 0 {
+			if !emit(puts[0]) {
+				done = true
+				return false
+			}
+			puts = puts[1:]
+		}
+		if len(puts) > 0 && order(puts[0].Key, e.Key) == 0 {
+			// the put replaces the entry in the tree.
+			e = puts[0]
+			puts = puts[1:]
+		} else if _, deleted := getEntry(s.deletes, e.Key, s.cmp); deleted {
+			return true
+		}
+		if !emit(e) {
+			done = true
+			return false
+		}
 		return true
 	})
-	return n, nil
+	for _, e := range puts {
+		if done || !emit(e) {
+			break
+		}
+	}
+}
+
+// putsFrom returns the pending puts in the order of span, starting from its first bound.
+// Descending spans get a reversed copy.
+func (s *memTxStore[K, V]) putsFrom(span state.Span[K]) []Entry[K, V] {
+	search := func(k K) int {
+		i, _ := slices.BinarySearchFunc(s.puts, Entry[K, V]{Key: k}, func(a, b Entry[K, V]) int {
+			return s.cmp(a.Key, b.Key)
+		})
+		return i
+	}
+	if !span.IsDesc() {
+		puts := s.puts
+		if lower, ok := span.LowerBound(); ok {
+			puts = puts[search(lower):]
+		}
+		return puts
+	}
+	end := len(s.puts)
+	if upper, ok := span.UpperBound(); ok {
+		end = search(upper)
+		if end < len(s.puts) && s.cmp(s.puts[end].Key, upper) == 0 {
+			end++
+		}
+	}
+	puts := make([]Entry[K, V], end)
+	for i := range puts {
+		puts[i] = s.puts[end-1-i]
+	}
+	return puts
+}
+
+// walkFrom calls fn with entries from the tree in the order of span, starting from its first bound.
+func (s *memTxStore[K, V]) walkFrom(span state.Span[K], fn func(Entry[K, V]) bool) {
+	if span.IsDesc() {
+		if upper, ok := span.UpperBound(); ok {
+			s.read.DescendLessOrEqual(Entry[K, V]{Key: upper}, fn)
+		} else {
+			s.read.Descend(fn)
+		}
+		return
+	}
+	if lower, ok := span.LowerBound(); ok {
+		s.read.AscendGreaterOrEqual(Entry[K, V]{Key: lower}, fn)
+	} else {
+		s.read.Ascend(fn)
+	}
 }
 
 func putEntry[K, V any, S []Entry[K, V]](s S, k K, v V, cmp func(a, b K) int) S {
@@ -2,6 +2,8 @@ package kv
 
 import (
 	"context"
+	"math/rand"
+	"sort"
 	"strings"
 	"testing"
 
@@ -33,3 +35,113 @@ func TestMemStore(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, []string{"a", "b", "c"}, ks)
 }
+
+func TestMemStoreTxList(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemStore[int, int](compareInts)
+	model := map[int]int{}
+	rng := rand.New(rand.NewSource(0))
+	for i := 0; i < 100; i++ {
+		k := rng.Intn(200)
+		require.NoError(t, s.Put(ctx, k, i))
+		model[k] = i
+	}
+	spans := []state.Span[int]{
+		state.TotalSpan[int](),
+		state.TotalSpan[int]().WithLowerIncl(50),
+		state.TotalSpan[int]().WithLowerExcl(50),
+		state.TotalSpan[int]().WithUpperIncl(150),
+		state.TotalSpan[int]().WithUpperExcl(150),
+		state.TotalSpan[int]().WithLowerExcl(20).WithUpperIncl(120),
+		state.PointSpan(7),
+		state.DescSpan[int](),
+		state.DescSpan[int]().WithLowerIncl(50),
+		state.DescSpan[int]().WithUpperExcl(150),
+		state.DescSpan[int]().WithLowerExcl(20).WithUpperIncl(120),
+	}
+	err := s.Modify(ctx, func(tx Store[int, int]) error {
+		for i := 0; i < 100; i++ {
+			k := rng.Intn(200)
+			if rng.Intn(2) == 0 {
+				require.NoError(t, tx.Put(ctx, k, -i))
+				model[k] = -i
+			} else {
+				require.NoError(t, tx.Delete(ctx, k))
+				delete(model, k)
+			}
+			for _, span := range spans {
+				for _, batchSize := range []int{1, 3, 1000} {
+					require.Equal(t, modelList(model, span), listAll(t, tx, span, batchSize), "span=%v", span)
+				}
+			}
+			for k := 0; k < 200; k++ {
+				yes, err := tx.Exists(ctx, k)
+				require.NoError(t, err)
+				_, expected := model[k]
+				require.Equal(t, expected, yes)
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	for _, span := range spans {
+		require.Equal(t, modelList(model, span), listAll(t, s, span, 7))
+	}
+}
+
+func TestListEntriesFallback(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemStore[int, int](compareInts)
+	for i := 0; i < 20; i++ {
+		require.NoError(t, s.Put(ctx, i, i*10))
+	}
+	// embedding the interface hides MemStore's ListEntries method.
+	x := struct{ Store[int, int] }{s}
+	var actual []Entry[int, int]
+	err := ForEachEntry[int, int](ctx, x, state.DescSpan[int]().WithLowerIncl(15), func(ent Entry[int, int]) error {
+		actual = append(actual, ent)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []Entry[int, int]{{19, 190}, {18, 180}, {17, 170}, {16, 160}, {15, 150}}, actual)
+}
+
+func listAll(t testing.TB, x Lister[int], span state.Span[int], batchSize int) []int {
+	ctx := context.Background()
+	ret := []int{}
+	buf := make([]int, batchSize)
+	for {
+		n, err := x.List(ctx, span, buf)
+		require.NoError(t, err)
+		if n == 0 {
+			return ret
+		}
+		ret = append(ret, buf[:n]...)
+		span = span.After(buf[n-1])
+	}
+}
+
+func modelList(model map[int]int, span state.Span[int]) []int {
+	ret := []int{}
+	for k := range model {
+		if span.Contains(k, compareInts) {
+			ret = append(ret, k)
+		}
+	}
+	sort.Ints(ret)
+	if span.IsDesc() {
+		sort.Sort(sort.Reverse(sort.IntSlice(ret)))
+	}
+	return ret
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
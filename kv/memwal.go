@@ -0,0 +1,330 @@
+package kv
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/btree"
+
+	"go.brendoncarroll.net/state/kv/internal/wal"
+	"go.brendoncarroll.net/state/posixfs"
+)
+
+// SyncPolicy determines when a MemStore's log is synced to stable storage.
+type SyncPolicy int
+
+const (
+	// SyncAlways syncs the log before each call to Modify returns.
+	SyncAlways SyncPolicy = iota
+	// SyncPeriodic syncs the log during a commit, if WALParams.SyncPeriod has passed since it was last synced.
+	SyncPeriodic
+	// SyncNever leaves writing the log to stable storage up to the operating system.
+	SyncNever
+)
+
+// DefaultCheckpointEvery is used if WALParams.CheckpointEvery is 0.
+const DefaultCheckpointEvery = 1024
+
+type WALParams[K, V any] struct {
+	// FS is where the snapshot and log are stored. It should be dedicated to the store.
+	FS         posixfs.FS
+	KeyCodec   Codec[K]
+	ValueCodec Codec[V]
+
+	Sync       SyncPolicy
+	SyncPeriod time.Duration
+	// CheckpointEvery is the number of commits after which the whole store is written to a snapshot,
+	// and the log is truncated.
+	CheckpointEvery int
+}
+
+const (
+	snapshotPath  = "SNAPSHOT"
+	snapshotMagic = "KVMEMSNP"
+)
+
+// OpenMemStore returns a MemStore which logs each committed transaction to params.FS.
+// The snapshot and log in params.FS are loaded first, if they exist.
+// A record at the end of the log which is incomplete or fails its checksum is discarded.
+// Call Close to release the log file.
+func OpenMemStore[K, V any](ctx context.Context, cmp func(a, b K) int, params WALParams[K, V]) (*MemStore[K, V], error) {
+	if params.CheckpointEvery <= 0 {
+		params.CheckpointEvery = DefaultCheckpointEvery
+	}
+	s := NewMemStore[K, V](cmp)
+	l := &memLog[K, V]{p: params}
+	gen, err := l.loadSnapshot(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := wal.Replay(ctx, params.FS, logPath(gen), func(payload []byte) error {
+		return l.applyBatch(s, payload)
+	}); err != nil && !posixfs.IsErrNotExist(err) {
+		return nil, err
+	}
+	l.gen = gen
+	s.log = l
+	// checkpoint immediately, so that new records are never appended after a torn one.
+	if err := s.Checkpoint(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Checkpoint writes the contents of the store to a new snapshot, and starts a new, empty, log.
+// It is called automatically every WALParams.CheckpointEvery commits.
+// Checkpoint does nothing for a MemStore without a log.
+func (s *MemStore[K, V]) Checkpoint(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return nil
+	}
+	return s.log.checkpoint(&s.tree)
+}
+
+// Close closes the log file. The store cannot be modified after it is closed.
+// Close does nothing for a MemStore without a log.
+func (s *MemStore[K, V]) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return nil
+	}
+	return s.log.close()
+}
+
+var errLogClosed = errors.New("kv: MemStore log is closed")
+
+// memLog writes MemStore transactions to a write-ahead log.
+type memLog[K, V any] struct {
+	p   WALParams[K, V]
+	gen uint64
+	w   *wal.Writer
+	// commits is the number of records appended since the last checkpoint.
+	commits  int
+	lastSync time.Time
+	// err is set if the log could not be written, the store cannot be modified after that.
+	err error
+}
+
+// append writes a record for a transaction.
+func (l *memLog[K, V]) append(puts []Entry[K, V], deletes []Entry[K, struct{}]) error {
+	if l.err != nil {
+		return l.err
+	}
+	if l.w == nil {
+		return errLogClosed
+	}
+	var batch []byte
+	for _, e := range puts {
+		batch = l.appendEntry(batch, opPut, e.Key, &e.Value)
+	}
+	for _, e := range deletes {
+		batch = l.appendEntry(batch, opDelete, e.Key, nil)
+	}
+	if err := l.w.Append(batch); err != nil {
+		l.err = fmt.Errorf("kv: appending to log: %w", err)
+		return err
+	}
+	if l.p.Sync == SyncAlways || (l.p.Sync == SyncPeriodic && time.Since(l.lastSync) >= l.p.SyncPeriod) {
+		if err := l.w.Sync(); err != nil {
+			l.err = fmt.Errorf("kv: syncing log: %w", err)
+			return err
+		}
+		l.lastSync = time.Now()
+	}
+	l.commits++
+	return nil
+}
+
+// checkpoint writes tree to a snapshot for the next generation, and starts a new log.
+func (l *memLog[K, V]) checkpoint(tree *btree.BTreeG[Entry[K, V]]) error {
+	if l.err != nil {
+		return l.err
+	}
+	next := l.gen + 1
+	tmpPath := snapshotPath + ".tmp"
+	if err := removeIfExists(l.p.FS, tmpPath); err != nil {
+		return err
+	}
+	w, err := wal.Create(l.p.FS, tmpPath)
+	if err != nil {
+		return err
+	}
+	header := []byte(snapshotMagic)
+	header = appendUint64(header, next)
+	header = appendUint64(header, uint64(tree.Len()))
+	err = w.Append(header)
+	var buf []byte
+	tree.Ascend(func(e Entry[K, V]) bool {
+		buf = l.appendEntry(buf[:0], opPut, e.Key, &e.Value)
+		err = w.Append(buf)
+		return err == nil
+	})
+	if err == nil {
+		err = w.Sync()
+	}
+	if err2 := w.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		return err
+	}
+	if err := l.p.FS.Rename(tmpPath, snapshotPath); err != nil {
+		return err
+	}
+	// the new snapshot has been committed, anything logged before it is no longer needed.
+	if l.w != nil {
+		l.w.Close()
+		l.w = nil
+	}
+	w, err = wal.Create(l.p.FS, logPath(next))
+	if err != nil {
+		l.err = err
+		return err
+	}
+	l.w, l.gen, l.commits, l.lastSync = w, next, 0, time.Now()
+	return l.removeOldLogs()
+}
+
+func (l *memLog[K, V]) close() error {
+	if l.w == nil {
+		return nil
+	}
+	err := l.w.Close()
+	l.w = nil
+	return err
+}
+
+// loadSnapshot reads the snapshot into s, and returns its generation.
+func (l *memLog[K, V]) loadSnapshot(ctx context.Context, s *MemStore[K, V]) (uint64, error) {
+	var gen, count, n uint64
+	var haveHeader bool
+	_, err := wal.Replay(ctx, l.p.FS, snapshotPath, func(payload []byte) error {
+		if !haveHeader {
+			if len(payload) != len(snapshotMagic)+16 || string(payload[:len(snapshotMagic)]) != snapshotMagic {
+				return errors.New("kv: invalid snapshot header")
+			}
+			payload = payload[len(snapshotMagic):]
+			gen = binary.BigEndian.Uint64(payload[0:8])
+			count = binary.BigEndian.Uint64(payload[8:16])
+			haveHeader = true
+			return nil
+		}
+		n++
+		return l.applyBatch(s, payload)
+	})
+	if posixfs.IsErrNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !haveHeader || n != count {
+		return 0, fmt.Errorf("kv: snapshot is incomplete, has %d of %d entries", n, count)
+	}
+	return gen, nil
+}
+
+// applyBatch applies the puts and deletes in a record to the tree, without logging them.
+func (l *memLog[K, V]) applyBatch(s *MemStore[K, V], batch []byte) error {
+	for len(batch) > 0 {
+		op := batch[0]
+		kdata, rest, ok := readLengthPrefixed(batch[1:])
+		if !ok {
+			return errCorruptLog
+		}
+		k, err := l.p.KeyCodec.Decode(kdata)
+		if err != nil {
+			return err
+		}
+		batch = rest
+		switch op {
+		case opPut:
+			vdata, rest, ok := readLengthPrefixed(batch)
+			if !ok {
+				return errCorruptLog
+			}
+			v, err := l.p.ValueCodec.Decode(vdata)
+			if err != nil {
+				return err
+			}
+			batch = rest
+			s.tree.ReplaceOrInsert(Entry[K, V]{Key: k, Value: v})
+		case opDelete:
+			s.tree.Delete(Entry[K, V]{Key: k})
+		default:
+			return errCorruptLog
+		}
+	}
+	return nil
+}
+
+func (l *memLog[K, V]) appendEntry(out []byte, op byte, k K, v *V) []byte {
+	out = append(out, op)
+	out = appendLengthPrefixed(out, l.p.KeyCodec.Encode(nil, k))
+	if v != nil {
+		out = appendLengthPrefixed(out, l.p.ValueCodec.Encode(nil, *v))
+	}
+	return out
+}
+
+func (l *memLog[K, V]) removeOldLogs() error {
+	ents, err := posixfs.ReadDir(l.p.FS, "")
+	if err != nil {
+		return err
+	}
+	for _, ent := range ents {
+		if strings.HasSuffix(ent.Name, ".log") && ent.Name != logPath(l.gen) {
+			if err := removeIfExists(l.p.FS, ent.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+const (
+	opPut    = 1
+	opDelete = 2
+)
+
+var errCorruptLog = errors.New("kv: corrupt log record")
+
+func logPath(gen uint64) string {
+	return fmt.Sprintf("%06d.log", gen)
+}
+
+func appendLengthPrefixed(out, x []byte) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(len(x)))
+	out = append(out, buf[:n]...)
+	return append(out, x...)
+}
+
+func readLengthPrefixed(data []byte) ([]byte, []byte, bool) {
+	n, l := binary.Uvarint(data)
+	if l <= 0 || uint64(len(data)-l) < n {
+		return nil, nil, false
+	}
+	data = data[l:]
+	return data[:n], data[n:], true
+}
+
+func appendUint64(out []byte, x uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], x)
+	return append(out, buf[:]...)
+}
+
+func removeIfExists(fsx posixfs.FS, p string) error {
+	if err := fsx.Remove(p); err != nil && !posixfs.IsErrNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package kv
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.brendoncarroll.net/state"
+	"go.brendoncarroll.net/state/posixfs"
+)
+
+func TestMemStoreWALReopen(t *testing.T) {
+	ctx := context.Background()
+	for _, policy := range []SyncPolicy{SyncAlways, SyncPeriodic, SyncNever} {
+		t.Run(fmt.Sprint("sync=", policy), func(t *testing.T) {
+			fsx := posixfs.NewTestFS(t)
+			s := openTestMemStore(t, fsx, policy, 10)
+			model := map[string]string{}
+			for i := 0; i < 55; i++ {
+				k := fmt.Sprint(i % 20)
+				if i%3 == 0 {
+					require.NoError(t, s.Delete(ctx, k))
+					delete(model, k)
+				} else {
+					require.NoError(t, s.Put(ctx, k, fmt.Sprint(i)))
+					model[k] = fmt.Sprint(i)
+				}
+			}
+			require.NoError(t, s.Close())
+			require.ErrorIs(t, s.Put(ctx, "a", "b"), errLogClosed)
+
+			s = openTestMemStore(t, fsx, policy, 10)
+			checkMemModel(t, s, model)
+		})
+	}
+}
+
+func TestMemStoreWALTornRecord(t *testing.T) {
+	ctx := context.Background()
+	fsx := posixfs.NewTestFS(t)
+	s := openTestMemStore(t, fsx, SyncAlways, 100)
+	require.NoError(t, s.Put(ctx, "a", "1"))
+	require.NoError(t, s.Put(ctx, "b", "2"))
+	gen := s.log.gen
+	require.NoError(t, s.Close())
+
+	data, err := posixfs.ReadFile(ctx, fsx, logPath(gen))
+	require.NoError(t, err)
+	require.NoError(t, posixfs.PutFile(ctx, fsx, logPath(gen), 0o644, bytes.NewReader(data[:len(data)-2])))
+
+	s = openTestMemStore(t, fsx, SyncAlways, 100)
+	checkMemModel(t, s, map[string]string{"a": "1"})
+	require.NoError(t, s.Put(ctx, "c", "3"))
+	require.NoError(t, s.Close())
+	s = openTestMemStore(t, fsx, SyncAlways, 100)
+	checkMemModel(t, s, map[string]string{"a": "1", "c": "3"})
+}
+
+func TestMemStoreWALCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	fsx := posixfs.NewTestFS(t)
+	s := openTestMemStore(t, fsx, SyncNever, 5)
+	gen := s.log.gen
+	for i := 0; i < 12; i++ {
+		require.NoError(t, s.Put(ctx, fmt.Sprint(i), "x"))
+	}
+	// two checkpoints have happened, and only the current log remains.
+	require.Equal(t, gen+2, s.log.gen)
+	require.Equal(t, 2, s.log.commits)
+	ents, err := posixfs.ReadDir(fsx, "")
+	require.NoError(t, err)
+	var names []string
+	for _, ent := range ents {
+		names = append(names, ent.Name)
+	}
+	require.ElementsMatch(t, []string{snapshotPath, logPath(s.log.gen)}, names)
+
+	// an aborted transaction is not logged.
+	err = s.Modify(ctx, func(tx Store[string, string]) error {
+		require.NoError(t, tx.Put(ctx, "aborted", "x"))
+		return fmt.Errorf("abort")
+	})
+	require.Error(t, err)
+	require.Equal(t, 2, s.log.commits)
+}
+
+func openTestMemStore(t testing.TB, fsx posixfs.FS, policy SyncPolicy, checkpointEvery int) *MemStore[string, string] {
+	s, err := OpenMemStore[string, string](context.Background(), compareStrings, WALParams[string, string]{
+		FS:              fsx,
+		KeyCodec:        StringCodec{},
+		ValueCodec:      StringCodec{},
+		Sync:            policy,
+		CheckpointEvery: checkpointEvery,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func checkMemModel(t testing.TB, s *MemStore[string, string], model map[string]string) {
+	ctx := context.Background()
+	require.Equal(t, len(model), s.Len())
+	err := ForEach[string](ctx, s, state.TotalSpan[string](), func(k string) error {
+		v, err := Get[string, string](ctx, s, k)
+		require.NoError(t, err)
+		require.Equal(t, model[k], v)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func compareStrings(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,145 @@
+package kv
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/btree"
+	"go.brendoncarroll.net/state"
+)
+
+var _ StoreTx[int, int] = &MVCCStore[int, int]{}
+var _ EntryLister[int, int] = &MVCCStore[int, int]{}
+
+// MVCCStore is an in memory StoreTx, which gives each reader a consistent snapshot.
+// Unlike MemStore, readers never block writers, and writers never block readers.
+// Writers are serialized, and commit by replacing the root of a copy-on-write btree.
+type MVCCStore[K, V any] struct {
+	cmp func(a, b K) int
+	// root is a *btree.BTreeG[Entry[K, V]], which is never modified once it is stored.
+	root atomic.Value
+
+	writeMu sync.Mutex
+}
+
+func NewMVCCStore[K, V any](cmp func(a, b K) int) *MVCCStore[K, V] {
+	s := &MVCCStore[K, V]{cmp: cmp}
+	s.root.Store(btree.NewG[Entry[K, V]](2, func(a, b Entry[K, V]) bool {
+		return cmp(a.Key, b.Key) < 0
+	}))
+	return s
+}
+
+func (s *MVCCStore[K, V]) Put(ctx context.Context, k K, v V) error {
+	return s.Modify(ctx, func(s Store[K, V]) error {
+		return s.Put(ctx, k, v)
+	})
+}
+
+func (s *MVCCStore[K, V]) Delete(ctx context.Context, k K) error {
+	return s.Modify(ctx, func(s Store[K, V]) error {
+		return s.Delete(ctx, k)
+	})
+}
+
+func (s *MVCCStore[K, V]) Exists(ctx context.Context, k K) (bool, error) {
+	return s.Snapshot().Exists(ctx, k)
+}
+
+func (s *MVCCStore[K, V]) Get(ctx context.Context, k K, dst *V) error {
+	return s.Snapshot().Get(ctx, k, dst)
+}
+
+func (s *MVCCStore[K, V]) List(ctx context.Context, span state.Span[K], buf []K) (int, error) {
+	return s.Snapshot().List(ctx, span, buf)
+}
+
+func (s *MVCCStore[K, V]) ListEntries(ctx context.Context, span state.Span[K], buf []Entry[K, V]) (int, error) {
+	return s.Snapshot().ListEntries(ctx, span, buf)
+}
+
+func (s *MVCCStore[K, V]) Len() int {
+	return s.Snapshot().Len()
+}
+
+// View calls fn with a snapshot of the store.
+// Commits made while fn is running are not visible to it.
+func (s *MVCCStore[K, V]) View(ctx context.Context, fn func(ReadOnlyStore[K, V]) error) error {
+	return fn(s.Snapshot())
+}
+
+// Modify calls fn with a mutable view of the store.
+// Calls to Modify are serialized, but they do not wait for readers.
+func (s *MVCCStore[K, V]) Modify(ctx context.Context, fn func(Store[K, V]) error) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	base := s.load()
+	tx := &memTxStore[K, V]{
+		read:    base,
+		puts:    make([]Entry[K, V], 0),
+		deletes: make([]Entry[K, struct{}], 0),
+		cmp:     s.cmp,
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if len(tx.puts) == 0 && len(tx.deletes) == 0 {
+		return nil
+	}
+	s.root.Store(tx.applyTo(base))
+	return nil
+}
+
+// Snapshot returns the current state of the store.
+// The Snapshot is not affected by later commits, and does not need to be released.
+func (s *MVCCStore[K, V]) Snapshot() *MemSnapshot[K, V] {
+	return &MemSnapshot[K, V]{
+		tx: memTxStore[K, V]{read: s.load(), cmp: s.cmp},
+	}
+}
+
+func (s *MVCCStore[K, V]) load() *btree.BTreeG[Entry[K, V]] {
+	return s.root.Load().(*btree.BTreeG[Entry[K, V]])
+}
+
+// applyTo returns a copy of base with the pending puts and deletes applied.
+// base is not modified.
+func (tx *memTxStore[K, V]) applyTo(base *btree.BTreeG[Entry[K, V]]) *btree.BTreeG[Entry[K, V]] {
+	next := base.Clone()
+	for _, e := range tx.puts {
+		next.ReplaceOrInsert(e)
+	}
+	for _, e := range tx.deletes {
+		next.Delete(Entry[K, V]{Key: e.Key})
+	}
+	return next
+}
+
+var _ ReadOnlyStore[int, int] = &MemSnapshot[int, int]{}
+var _ EntryLister[int, int] = &MemSnapshot[int, int]{}
+
+// MemSnapshot is an immutable view of an MVCCStore at a point in time.
+type MemSnapshot[K, V any] struct {
+	tx memTxStore[K, V]
+}
+
+func (s *MemSnapshot[K, V]) Get(ctx context.Context, k K, dst *V) error {
+	return s.tx.Get(ctx, k, dst)
+}
+
+func (s *MemSnapshot[K, V]) List(ctx context.Context, span state.Span[K], buf []K) (int, error) {
+	return s.tx.List(ctx, span, buf)
+}
+
+func (s *MemSnapshot[K, V]) ListEntries(ctx context.Context, span state.Span[K], buf []Entry[K, V]) (int, error) {
+	return s.tx.ListEntries(ctx, span, buf)
+}
+
+func (s *MemSnapshot[K, V]) Exists(ctx context.Context, k K) (bool, error) {
+	return ExistsUsingList[K](ctx, s, k)
+}
+
+func (s *MemSnapshot[K, V]) Len() int {
+	return s.tx.read.Len()
+}
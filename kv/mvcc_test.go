@@ -0,0 +1,116 @@
+package kv
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.brendoncarroll.net/state"
+)
+
+func TestMVCCStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMVCCStore[int, int](compareInts)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, s.Put(ctx, i, i))
+	}
+	require.NoError(t, s.Delete(ctx, 9))
+	v, err := Get[int, int](ctx, s, 3)
+	require.NoError(t, err)
+	require.Equal(t, 3, v)
+	_, err = Get[int, int](ctx, s, 9)
+	require.True(t, state.IsErrNotFound[int](err))
+	require.Equal(t, 9, s.Len())
+	require.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8}, listAll(t, s, state.TotalSpan[int](), 4))
+
+	// a failed Modify is not applied.
+	err = s.Modify(ctx, func(tx Store[int, int]) error {
+		require.NoError(t, tx.Put(ctx, 100, 100))
+		return context.Canceled
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	yes, err := s.Exists(ctx, 100)
+	require.NoError(t, err)
+	require.False(t, yes)
+}
+
+func TestMVCCSnapshot(t *testing.T) {
+	ctx := context.Background()
+	s := NewMVCCStore[int, int](compareInts)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, s.Put(ctx, i, i))
+	}
+	snap := s.Snapshot()
+	err := s.Modify(ctx, func(tx Store[int, int]) error {
+		for i := 0; i < 100; i += 2 {
+			if err := tx.Delete(ctx, i); err != nil {
+				return err
+			}
+		}
+		return tx.Put(ctx, 1, -1)
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, 100, snap.Len())
+	v, err := Get[int, int](ctx, snap, 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, v)
+	require.Len(t, listAll(t, snap, state.TotalSpan[int](), 10), 100)
+
+	require.Equal(t, 50, s.Len())
+	v, err = Get[int, int](ctx, s, 1)
+	require.NoError(t, err)
+	require.Equal(t, -1, v)
+}
+
+func TestMVCCViewDoesNotBlockWriters(t *testing.T) {
+	ctx := context.Background()
+	s := NewMVCCStore[int, int](compareInts)
+	require.NoError(t, s.Put(ctx, 0, 0))
+
+	err := s.View(ctx, func(tx ReadOnlyStore[int, int]) error {
+		done := make(chan error)
+		go func() {
+			done <- s.Put(ctx, 1, 1)
+		}()
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("writer blocked by reader")
+		}
+		require.Equal(t, []int{0}, listAll(t, tx, state.TotalSpan[int](), 10))
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1}, listAll(t, s, state.TotalSpan[int](), 10))
+}
+
+func TestMVCCConcurrent(t *testing.T) {
+	ctx := context.Background()
+	s := NewMVCCStore[int, int](compareInts)
+	const n = 1000
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			require.NoError(t, s.Put(ctx, i, i))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			// every snapshot holds a prefix of the keys.
+			snap := s.Snapshot()
+			ks := listAll(t, snap, state.TotalSpan[int](), 64)
+			for j, k := range ks {
+				require.Equal(t, j, k)
+			}
+		}
+	}()
+	wg.Wait()
+	require.Equal(t, n, s.Len())
+}
@@ -0,0 +1,279 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/google/btree"
+	"go.brendoncarroll.net/state"
+)
+
+// ErrConflict is returned when a transaction could not be committed,
+// because Key was read by the transaction and modified by another transaction which committed first.
+type ErrConflict[K any] struct {
+	Key K
+}
+
+func (e ErrConflict[K]) Error() string {
+	return fmt.Sprintf("transaction conflict on key %v", e.Key)
+}
+
+func IsErrConflict[K any](err error) bool {
+	return errors.As(err, &ErrConflict[K]{})
+}
+
+var _ StoreTx[int, int] = &OptimisticStore[int, int]{}
+var _ EntryLister[int, int] = &OptimisticStore[int, int]{}
+
+// OptimisticStore is an in memory StoreTx, which uses optimistic concurrency control.
+// Calls to Modify run concurrently against a snapshot of the store, recording the keys and spans they read.
+// At commit time, if any key written by a transaction which committed in the meantime is in the read set,
+// Modify returns ErrConflict, and none of the writes are applied.
+// Transactions which only read disjoint keys can commit in parallel. Use Retry to run fn until it commits.
+type OptimisticStore[K, V any] struct {
+	cmp func(a, b K) int
+
+	mu      sync.Mutex
+	root    *btree.BTreeG[Entry[K, V]]
+	version uint64
+	// active holds the number of running transactions, by the version they started from.
+	active map[uint64]int
+	// log holds the keys written by each commit, which a running transaction may not have seen.
+	log []commitRecord[K]
+}
+
+type commitRecord[K any] struct {
+	version uint64
+	keys    []K
+}
+
+func NewOptimisticStore[K, V any](cmp func(a, b K) int) *OptimisticStore[K, V] {
+	return &OptimisticStore[K, V]{
+		cmp: cmp,
+		root: btree.NewG[Entry[K, V]](2, func(a, b Entry[K, V]) bool {
+			return cmp(a.Key, b.Key) < 0
+		}),
+		active: make(map[uint64]int),
+	}
+}
+
+func (s *OptimisticStore[K, V]) Put(ctx context.Context, k K, v V) error {
+	return s.Modify(ctx, func(s Store[K, V]) error {
+		return s.Put(ctx, k, v)
+	})
+}
+
+func (s *OptimisticStore[K, V]) Delete(ctx context.Context, k K) error {
+	return s.Modify(ctx, func(s Store[K, V]) error {
+		return s.Delete(ctx, k)
+	})
+}
+
+func (s *OptimisticStore[K, V]) Exists(ctx context.Context, k K) (bool, error) {
+	return s.Snapshot().Exists(ctx, k)
+}
+
+func (s *OptimisticStore[K, V]) Get(ctx context.Context, k K, dst *V) error {
+	return s.Snapshot().Get(ctx, k, dst)
+}
+
+func (s *OptimisticStore[K, V]) List(ctx context.Context, span state.Span[K], buf []K) (int, error) {
+	return s.Snapshot().List(ctx, span, buf)
+}
+
+func (s *OptimisticStore[K, V]) ListEntries(ctx context.Context, span state.Span[K], buf []Entry[K, V]) (int, error) {
+	return s.Snapshot().ListEntries(ctx, span, buf)
+}
+
+func (s *OptimisticStore[K, V]) Len() int {
+	return s.Snapshot().Len()
+}
+
+// View calls fn with a snapshot of the store.
+func (s *OptimisticStore[K, V]) View(ctx context.Context, fn func(ReadOnlyStore[K, V]) error) error {
+	return fn(s.Snapshot())
+}
+
+// Modify calls fn with a mutable view of a snapshot of the store, then tries to commit the changes.
+// If the transaction conflicts with another, ErrConflict is returned.
+func (s *OptimisticStore[K, V]) Modify(ctx context.Context, fn func(Store[K, V]) error) error {
+	s.mu.Lock()
+	base, startVersion := s.root, s.version
+	s.active[startVersion]++
+	s.mu.Unlock()
+	defer s.finish(startVersion)
+
+	tx := &optimisticTx[K, V]{
+		memTxStore: memTxStore[K, V]{
+			read:    base,
+			puts:    make([]Entry[K, V], 0),
+			deletes: make([]Entry[K, struct{}], 0),
+			cmp:     s.cmp,
+		},
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if len(tx.puts) == 0 && len(tx.deletes) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rec := range s.log {
+		if rec.version <= startVersion {
+			continue
+		}
+		for _, k := range rec.keys {
+			if tx.hasRead(k) {
+				return ErrConflict[K]{Key: k}
+			}
+		}
+	}
+	s.root = tx.applyTo(s.root)
+	s.version++
+	if len(s.active) > 1 || s.active[startVersion] > 1 {
+		// other transactions are running, which will need to validate against these writes.
+		s.log = append(s.log, commitRecord[K]{version: s.version, keys: tx.writtenKeys()})
+	}
+	return nil
+}
+
+// Snapshot returns the current state of the store.
+// The Snapshot is not affected by later commits, and does not need to be released.
+func (s *OptimisticStore[K, V]) Snapshot() *MemSnapshot[K, V] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &MemSnapshot[K, V]{
+		tx: memTxStore[K, V]{read: s.root, cmp: s.cmp},
+	}
+}
+
+// finish marks a transaction as no longer running, and trims the commit log.
+func (s *OptimisticStore[K, V]) finish(startVersion uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active[startVersion]--
+	if s.active[startVersion] == 0 {
+		delete(s.active, startVersion)
+	}
+	oldest := s.version
+	for v := range s.active {
+		if v < oldest {
+			oldest = v
+		}
+	}
+	var i int
+	for i < len(s.log) && s.log[i].version <= oldest {
+		i++
+	}
+	s.log = append(s.log[:0], s.log[i:]...)
+}
+
+// optimisticTx is a memTxStore which records the keys and spans that it reads.
+type optimisticTx[K, V any] struct {
+	memTxStore[K, V]
+	readKeys  []Entry[K, struct{}]
+	readSpans []state.Span[K]
+}
+
+func (tx *optimisticTx[K, V]) Get(ctx context.Context, k K, dst *V) error {
+	tx.readKeys = putEntry(tx.readKeys, k, struct{}{}, tx.cmp)
+	return tx.memTxStore.Get(ctx, k, dst)
+}
+
+func (tx *optimisticTx[K, V]) Exists(ctx context.Context, k K) (bool, error) {
+	return ExistsUsingList[K](ctx, tx, k)
+}
+
+func (tx *optimisticTx[K, V]) List(ctx context.Context, span state.Span[K], buf []K) (int, error) {
+	n, err := tx.memTxStore.List(ctx, span, buf)
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 && n == len(buf) {
+		tx.readSpan(span, buf[n-1])
+	} else {
+		tx.readSpans = append(tx.readSpans, span)
+	}
+	return n, nil
+}
+
+func (tx *optimisticTx[K, V]) ListEntries(ctx context.Context, span state.Span[K], buf []Entry[K, V]) (int, error) {
+	n, err := tx.memTxStore.ListEntries(ctx, span, buf)
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 && n == len(buf) {
+		tx.readSpan(span, buf[n-1].Key)
+	} else {
+		tx.readSpans = append(tx.readSpans, span)
+	}
+	return n, nil
+}
+
+// readSpan records that span has been read up to and including last.
+func (tx *optimisticTx[K, V]) readSpan(span state.Span[K], last K) {
+	if span.IsDesc() {
+		span = span.WithLowerIncl(last)
+	} else {
+		span = span.WithUpperIncl(last)
+	}
+	tx.readSpans = append(tx.readSpans, span)
+}
+
+// hasRead returns true if the transaction's result could depend on k.
+func (tx *optimisticTx[K, V]) hasRead(k K) bool {
+	if _, yes := getEntry(tx.readKeys, k, tx.cmp); yes {
+		return true
+	}
+	for _, span := range tx.readSpans {
+		if span.Contains(k, tx.cmp) {
+			return true
+		}
+	}
+	return false
+}
+
+func (tx *optimisticTx[K, V]) writtenKeys() []K {
+	ret := make([]K, 0, len(tx.puts)+len(tx.deletes))
+	for _, e := range tx.puts {
+		ret = append(ret, e.Key)
+	}
+	for _, e := range tx.deletes {
+		ret = append(ret, e.Key)
+	}
+	return ret
+}
+
+const (
+	retryMinBackoff = time.Millisecond
+	retryMaxBackoff = 100 * time.Millisecond
+)
+
+// Retry calls s.Modify with fn until it does not return ErrConflict.
+// It waits for a random, exponentially increasing, backoff between attempts.
+// Retry gives up when ctx is cancelled.
+func Retry[K, V any](ctx context.Context, s StoreTx[K, V], fn func(Store[K, V]) error) error {
+	backoff := retryMinBackoff
+	for {
+		err := s.Modify(ctx, fn)
+		if !IsErrConflict[K](err) {
+			return err
+		}
+		timer := time.NewTimer(time.Duration(rand.Int63n(int64(backoff))) + 1)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > retryMaxBackoff {
+			backoff = retryMaxBackoff
+		}
+	}
+}
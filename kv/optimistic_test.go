@@ -0,0 +1,101 @@
+package kv
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.brendoncarroll.net/state"
+)
+
+func TestOptimisticConflict(t *testing.T) {
+	ctx := context.Background()
+	s := NewOptimisticStore[int, int](compareInts)
+	require.NoError(t, s.Put(ctx, 1, 1))
+
+	// tx1 reads key 1, and tx2 writes it and commits while tx1 is running.
+	err := s.Modify(ctx, func(tx Store[int, int]) error {
+		_, err := Get[int, int](ctx, tx, 1)
+		require.NoError(t, err)
+		require.NoError(t, s.Put(ctx, 1, 2))
+		return tx.Put(ctx, 2, 2)
+	})
+	require.True(t, IsErrConflict[int](err), "%v", err)
+	yes, err := s.Exists(ctx, 2)
+	require.NoError(t, err)
+	require.False(t, yes)
+
+	// a span read conflicts with inserts into the span.
+	err = s.Modify(ctx, func(tx Store[int, int]) error {
+		buf := make([]int, 10)
+		_, err := tx.List(ctx, state.TotalSpan[int]().WithLowerIncl(10).WithUpperExcl(20), buf)
+		require.NoError(t, err)
+		require.NoError(t, s.Put(ctx, 15, 15))
+		return tx.Put(ctx, 3, 3)
+	})
+	require.True(t, IsErrConflict[int](err), "%v", err)
+
+	// so does a span read with ListEntries.
+	err = s.Modify(ctx, func(tx Store[int, int]) error {
+		buf := make([]Entry[int, int], 10)
+		_, err := ListEntries[int, int](ctx, tx, state.TotalSpan[int]().WithLowerIncl(10).WithUpperExcl(20), buf)
+		require.NoError(t, err)
+		require.NoError(t, s.Put(ctx, 16, 16))
+		return tx.Put(ctx, 3, 3)
+	})
+	require.True(t, IsErrConflict[int](err), "%v", err)
+
+	// writes outside of the read set do not conflict.
+	err = s.Modify(ctx, func(tx Store[int, int]) error {
+		_, err := Get[int, int](ctx, tx, 1)
+		require.NoError(t, err)
+		require.NoError(t, s.Put(ctx, 100, 100))
+		return tx.Put(ctx, 4, 4)
+	})
+	require.NoError(t, err)
+	v, err := Get[int, int](ctx, s, 4)
+	require.NoError(t, err)
+	require.Equal(t, 4, v)
+}
+
+func TestOptimisticRetry(t *testing.T) {
+	ctx := context.Background()
+	s := NewOptimisticStore[int, int](compareInts)
+	const workers = 8
+	const incrs = 50
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < incrs; j++ {
+				// every worker increments a shared counter, and its own key.
+				for _, k := range []int{0, i + 1} {
+					err := Retry[int, int](ctx, s, func(tx Store[int, int]) error {
+						var v int
+						if err := tx.Get(ctx, k, &v); err != nil && !state.IsErrNotFound[int](err) {
+							return err
+						}
+						return tx.Put(ctx, k, v+1)
+					})
+					require.NoError(t, err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	for k := 0; k <= workers; k++ {
+		v, err := Get[int, int](ctx, s, k)
+		require.NoError(t, err)
+		if k == 0 {
+			require.Equal(t, workers*incrs, v)
+		} else {
+			require.Equal(t, incrs, v)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	require.Empty(t, s.log)
+	require.Empty(t, s.active)
+}
@@ -0,0 +1,195 @@
+package kv
+
+import (
+	"context"
+
+	"go.brendoncarroll.net/state"
+)
+
+var _ Store[[]byte, int] = &Prefixed[int]{}
+var _ StoreTx[[]byte, int] = &PrefixedTx[int]{}
+
+// Prefixed is a namespace within a Store with []byte keys.
+// It contains the entries of the inner Store whose keys start with a prefix, and the prefix is removed from their keys.
+//
+// Prefixed can wrap the Store passed to StoreTx.Modify, so several namespaces can be changed in one transaction:
+//
+//	err := s.Modify(ctx, func(tx kv.Store[[]byte, V]) error {
+//		users := kv.NewPrefixed(tx, []byte("users/"))
+//		groups := kv.NewPrefixed(tx, []byte("groups/"))
+//		...
+//	})
+type Prefixed[V any] struct {
+	inner  Store[[]byte, V]
+	prefix []byte
+}
+
+// NewPrefixed returns the namespace of inner which holds keys starting with prefix.
+func NewPrefixed[V any](inner Store[[]byte, V], prefix []byte) *Prefixed[V] {
+	return &Prefixed[V]{
+		inner:  inner,
+		prefix: append([]byte{}, prefix...),
+	}
+}
+
+func (s *Prefixed[V]) Put(ctx context.Context, k []byte, v V) error {
+	return s.inner.Put(ctx, s.key(k), v)
+}
+
+func (s *Prefixed[V]) Delete(ctx context.Context, k []byte) error {
+	return s.inner.Delete(ctx, s.key(k))
+}
+
+func (s *Prefixed[V]) DeleteSpan(ctx context.Context, span state.Span[[]byte]) (int, error) {
+	return DeleteSpan[[]byte](ctx, s.inner, prefixedSpan(s.prefix, span))
+}
+
+func (s *Prefixed[V]) Get(ctx context.Context, k []byte, dst *V) error {
+	return prefixedGet[V](ctx, s.inner, s.prefix, k, dst)
+}
+
+func (s *Prefixed[V]) Exists(ctx context.Context, k []byte) (bool, error) {
+	return s.inner.Exists(ctx, s.key(k))
+}
+
+func (s *Prefixed[V]) List(ctx context.Context, span state.Span[[]byte], buf [][]byte) (int, error) {
+	return prefixedList[V](ctx, s.inner, s.prefix, span, buf)
+}
+
+func (s *Prefixed[V]) ListEntries(ctx context.Context, span state.Span[[]byte], buf []Entry[[]byte, V]) (int, error) {
+	return prefixedListEntries[V](ctx, s.inner, s.prefix, span, buf)
+}
+
+func (s *Prefixed[V]) key(k []byte) []byte {
+	return prefixKey(s.prefix, k)
+}
+
+// PrefixedTx is a namespace within a StoreTx with []byte keys.
+// See Prefixed.
+type PrefixedTx[V any] struct {
+	inner  StoreTx[[]byte, V]
+	prefix []byte
+}
+
+// NewPrefixedTx returns the namespace of inner which holds keys starting with prefix.
+// Each transaction on the namespace is a transaction on inner.
+func NewPrefixedTx[V any](inner StoreTx[[]byte, V], prefix []byte) *PrefixedTx[V] {
+	return &PrefixedTx[V]{
+		inner:  inner,
+		prefix: append([]byte{}, prefix...),
+	}
+}
+
+func (s *PrefixedTx[V]) View(ctx context.Context, fn func(ReadOnlyStore[[]byte, V]) error) error {
+	return s.inner.View(ctx, func(tx ReadOnlyStore[[]byte, V]) error {
+		return fn(prefixedReader[V]{inner: tx, prefix: s.prefix})
+	})
+}
+
+func (s *PrefixedTx[V]) Modify(ctx context.Context, fn func(Store[[]byte, V]) error) error {
+	return s.inner.Modify(ctx, func(tx Store[[]byte, V]) error {
+		return fn(&Prefixed[V]{inner: tx, prefix: s.prefix})
+	})
+}
+
+// prefixedReader is a read only Prefixed.
+type prefixedReader[V any] struct {
+	inner  ReadOnlyStore[[]byte, V]
+	prefix []byte
+}
+
+func (s prefixedReader[V]) Get(ctx context.Context, k []byte, dst *V) error {
+	return prefixedGet[V](ctx, s.inner, s.prefix, k, dst)
+}
+
+func (s prefixedReader[V]) List(ctx context.Context, span state.Span[[]byte], buf [][]byte) (int, error) {
+	return prefixedList[V](ctx, s.inner, s.prefix, span, buf)
+}
+
+func (s prefixedReader[V]) ListEntries(ctx context.Context, span state.Span[[]byte], buf []Entry[[]byte, V]) (int, error) {
+	return prefixedListEntries[V](ctx, s.inner, s.prefix, span, buf)
+}
+
+func prefixedGet[V any](ctx context.Context, inner Getter[[]byte, V], prefix, k []byte, dst *V) error {
+	if err := inner.Get(ctx, prefixKey(prefix, k), dst); err != nil {
+		if state.IsErrNotFound[[]byte](err) {
+			return state.ErrNotFound[[]byte]{Key: k}
+		}
+		return err
+	}
+	return nil
+}
+
+func prefixedList[V any](ctx context.Context, inner ReadOnlyStore[[]byte, V], prefix []byte, span state.Span[[]byte], buf [][]byte) (int, error) {
+	n, err := inner.List(ctx, prefixedSpan(prefix, span), buf)
+	if err != nil {
+		return 0, err
+	}
+	for i := range buf[:n] {
+		buf[i] = buf[i][len(prefix):]
+	}
+	return n, nil
+}
+
+func prefixedListEntries[V any](ctx context.Context, inner ReadOnlyStore[[]byte, V], prefix []byte, span state.Span[[]byte], buf []Entry[[]byte, V]) (int, error) {
+	n, err := ListEntries(ctx, inner, prefixedSpan(prefix, span), buf)
+	if err != nil {
+		return 0, err
+	}
+	for i := range buf[:n] {
+		buf[i].Key = buf[i].Key[len(prefix):]
+	}
+	return n, nil
+}
+
+func prefixKey(prefix, k []byte) []byte {
+	ret := make([]byte, 0, len(prefix)+len(k))
+	ret = append(ret, prefix...)
+	return append(ret, k...)
+}
+
+// prefixedSpan converts a span of keys within the namespace to the span of the same keys in the inner store.
+// Missing bounds are replaced with the bounds of PrefixSpan(prefix).
+func prefixedSpan(prefix []byte, span state.Span[[]byte]) state.Span[[]byte] {
+	ret := PrefixSpan(prefix)
+	if lower, ok := span.LowerBound(); ok {
+		if span.IncludesLower() {
+			ret = ret.WithLowerIncl(prefixKey(prefix, lower))
+		} else {
+			ret = ret.WithLowerExcl(prefixKey(prefix, lower))
+		}
+	}
+	if upper, ok := span.UpperBound(); ok {
+		if span.IncludesUpper() {
+			ret = ret.WithUpperIncl(prefixKey(prefix, upper))
+		} else {
+			ret = ret.WithUpperExcl(prefixKey(prefix, upper))
+		}
+	}
+	if span.IsDesc() {
+		ret = ret.Desc()
+	}
+	return ret
+}
+
+// PrefixSpan returns the Span of all the keys which start with prefix.
+func PrefixSpan(prefix []byte) state.Span[[]byte] {
+	ret := state.TotalSpan[[]byte]().WithLowerIncl(append([]byte{}, prefix...))
+	if end := prefixEnd(prefix); end != nil {
+		ret = ret.WithUpperExcl(end)
+	}
+	return ret
+}
+
+// prefixEnd returns the first key after all the keys starting with prefix.
+// It returns nil if there is no such key, which happens when prefix is empty or all 0xff.
+func prefixEnd(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] < 0xff {
+			end := append([]byte{}, prefix[:i+1]...)
+			end[i]++
+			return end
+		}
+	}
+	return nil
+}
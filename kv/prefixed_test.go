@@ -0,0 +1,87 @@
+package kv
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.brendoncarroll.net/state"
+)
+
+func TestPrefixSpan(t *testing.T) {
+	for _, tc := range []struct {
+		Prefix string
+		In     []string
+		Out    []string
+	}{
+		{"", []string{"", "a", "\xff"}, nil},
+		{"ab", []string{"ab", "ab\x00", "ab\xff\xff"}, []string{"a", "aa\xff", "ac", "b"}},
+		{"a\xff", []string{"a\xff", "a\xff\xff"}, []string{"a", "a\xfe\xff", "b"}},
+		{"\xff\xff", []string{"\xff\xff", "\xff\xff\x00"}, []string{"\xff", "\xfe"}},
+	} {
+		span := PrefixSpan([]byte(tc.Prefix))
+		for _, k := range tc.In {
+			require.True(t, span.Contains([]byte(k), bytes.Compare), "%q should contain %q", tc.Prefix, k)
+		}
+		for _, k := range tc.Out {
+			require.False(t, span.Contains([]byte(k), bytes.Compare), "%q should not contain %q", tc.Prefix, k)
+		}
+	}
+}
+
+func TestPrefixedTxAtomic(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemStore[[]byte, int](bytes.Compare)
+	users := NewPrefixedTx[int](s, []byte("users/"))
+	errAbort := errors.New("abort")
+	modify := func(abort bool) error {
+		return s.Modify(ctx, func(tx Store[[]byte, int]) error {
+			users, groups := NewPrefixed(tx, []byte("users/")), NewPrefixed(tx, []byte("groups/"))
+			if err := users.Put(ctx, []byte("alice"), 1); err != nil {
+				return err
+			}
+			if err := groups.Put(ctx, []byte("admins"), 1); err != nil {
+				return err
+			}
+			if abort {
+				return errAbort
+			}
+			return nil
+		})
+	}
+	require.ErrorIs(t, modify(true), errAbort)
+	require.Equal(t, 0, s.Len())
+	require.NoError(t, modify(false))
+	require.Equal(t, 2, s.Len())
+
+	err := users.View(ctx, func(tx ReadOnlyStore[[]byte, int]) error {
+		v, err := Get[[]byte, int](ctx, tx, []byte("alice"))
+		require.NoError(t, err)
+		require.Equal(t, 1, v)
+		_, err = Get[[]byte, int](ctx, tx, []byte("admins"))
+		require.True(t, state.IsErrNotFound[[]byte](err))
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func ExamplePrefixed() {
+	ctx := context.Background()
+	s := NewMemStore[[]byte, string](bytes.Compare)
+	s.Put(ctx, []byte("users/alice"), "Alice")
+	s.Put(ctx, []byte("users/bob"), "Bob")
+	s.Put(ctx, []byte("groups/admins"), "alice")
+
+	users := NewPrefixed[string](s, []byte("users/"))
+	ForEachEntry[[]byte, string](ctx, users, state.TotalSpan[[]byte](), func(ent Entry[[]byte, string]) error {
+		fmt.Printf("%s: %s\n", ent.Key, ent.Value)
+		return nil
+	})
+
+	// Output:
+	// alice: Alice
+	// bob: Bob
+}
@@ -45,9 +45,18 @@ type Lister[K any] interface {
 	// List signals the end of the list by returning (0, nil)
 	// List may fill ks with fewer than len(ks), but will always return > 0, unless it is the end.
 	// List will only return keys which are contained by in the span.
+	// Keys are in ascending order, or descending order if span.IsDesc().
 	List(ctx context.Context, span state.Span[K], ks []K) (int, error)
 }
 
+// EntryLister has the ListEntries method.
+type EntryLister[K, V any] interface {
+	// ListEntries copies entries from the store into buf, and returns the number copied.
+	// It follows the same rules as List, with each entry holding a key and its value.
+	// The keys and values are read from the same state of the store.
+	ListEntries(ctx context.Context, span state.Span[K], buf []Entry[K, V]) (int, error)
+}
+
 // Exister has the Exists method
 type Exister[K any] interface {
 	// Exists returns true if the store contains an entry for k and false otherwise.
@@ -59,6 +68,19 @@ type Getter[K, V any] interface {
 	Get(ctx context.Context, k K, dst *V) error
 }
 
+// SpanDeleter has the DeleteSpan method.
+// It is implemented by stores which can remove a range of keys more efficiently than one at a time.
+type SpanDeleter[K any] interface {
+	// DeleteSpan removes all the keys contained by span, and returns the number removed.
+	DeleteSpan(ctx context.Context, span state.Span[K]) (int, error)
+}
+
+// ListDeleter combines the Lister and Deleter interfaces.
+type ListDeleter[K any] interface {
+	Lister[K]
+	Deleter[K]
+}
+
 // Store is an ordered Key-Value Store
 type Store[K, V any] interface {
 	Getter[K, V]
@@ -84,7 +106,7 @@ type StoreTx[K, V any] interface {
 	Modify(ctx context.Context, fn func(tx Store[K, V]) error) error
 }
 
-// ForEach calls fn with all the keys in x constrained by gteq, and lt if they exist.
+// ForEach calls fn with all the keys in x contained by span, in the order of the span.
 // `fn` may be called in another go rountine during the execution of ForEachSpan.
 // `fn` will not be called after ForEachSpan returns.
 func ForEach[K any](ctx context.Context, x Lister[K], span state.Span[K], fn func(K) error) error {
@@ -112,7 +134,7 @@ func ForEach[K any](ctx context.Context, x Lister[K], span state.Span[K], fn fun
 				case ch <- k:
 				}
 			}
-			span = span.WithLowerExcl(items[len(items)-1])
+			span = span.After(items[len(items)-1])
 		}
 	})
 	eg.Go(func() error {
@@ -126,11 +148,106 @@ func ForEach[K any](ctx context.Context, x Lister[K], span state.Span[K], fn fun
 	return eg.Wait()
 }
 
+// ListEntries copies the entries in span from x into buf, and returns the number copied.
+// If x is an EntryLister, its ListEntries method is used.
+// Otherwise the keys are listed and each value is read with Get, so the values may be from a later state than the keys.
+func ListEntries[K, V any](ctx context.Context, x ReadOnlyStore[K, V], span state.Span[K], buf []Entry[K, V]) (int, error) {
+	if el, ok := x.(EntryLister[K, V]); ok {
+		return el.ListEntries(ctx, span, buf)
+	}
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	keys := make([]K, len(buf))
+	for {
+		n, err := x.List(ctx, span, keys)
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			return 0, nil
+		}
+		var count int
+		for _, k := range keys[:n] {
+			var v V
+			if err := x.Get(ctx, k, &v); err != nil {
+				if state.IsErrNotFound[K](err) {
+					// deleted since it was listed
+					continue
+				}
+				return 0, err
+			}
+			buf[count] = Entry[K, V]{Key: k, Value: v}
+			count++
+		}
+		if count > 0 {
+			return count, nil
+		}
+		span = span.After(keys[n-1])
+	}
+}
+
+// ForEachEntry calls fn with all the entries in x with keys contained by span, in the order of the span.
+// The entries are read in batches using ListEntries, and fn is called from the calling goroutine.
+// Each batch is consistent, but to see a single state of a StoreTx for the whole iteration, call ForEachEntry inside View.
+func ForEachEntry[K, V any](ctx context.Context, x ReadOnlyStore[K, V], span state.Span[K], fn func(Entry[K, V]) error) error {
+	const batchSize = 16
+	buf := make([]Entry[K, V], batchSize)
+	for {
+		n, err := ListEntries(ctx, x, span, buf)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+		for _, ent := range buf[:n] {
+			if err := fn(ent); err != nil {
+				return err
+			}
+		}
+		span = span.After(buf[n-1].Key)
+	}
+}
+
 // Get is a convenience function for retrieving a value without declaring a destination variable.
 func Get[K, V any](ctx context.Context, x Getter[K, V], k K) (ret V, _ error) {
 	return ret, x.Get(ctx, k, &ret)
 }
 
+// DeleteSpan removes all the keys in span from s, and returns the number removed.
+// If s is a SpanDeleter, its DeleteSpan method is used, otherwise DeleteSpanBasic is.
+func DeleteSpan[K any](ctx context.Context, s ListDeleter[K], span state.Span[K]) (int, error) {
+	if sd, ok := s.(SpanDeleter[K]); ok {
+		return sd.DeleteSpan(ctx, span)
+	}
+	return DeleteSpanBasic(ctx, s, span)
+}
+
+// DeleteSpanBasic implements DeleteSpan by listing the keys in span, and deleting them one at a time.
+// It is not atomic, if it returns an error, some of the keys may have been deleted.
+func DeleteSpanBasic[K any](ctx context.Context, s ListDeleter[K], span state.Span[K]) (int, error) {
+	const batchSize = 64
+	buf := make([]K, batchSize)
+	var count int
+	for {
+		n, err := s.List(ctx, span, buf)
+		if err != nil {
+			return count, err
+		}
+		if n == 0 {
+			return count, nil
+		}
+		for _, k := range buf[:n] {
+			if err := s.Delete(ctx, k); err != nil {
+				return count, err
+			}
+			count++
+		}
+		span = span.After(buf[n-1])
+	}
+}
+
 // ExistsUsingList implements Exists in terms of List
 func ExistsUsingList[K any](ctx context.Context, s Lister[K], k K) (bool, error) {
 	span := state.PointSpan(k)
@@ -0,0 +1,365 @@
+// Package tuple encodes tuples of values as bytes, such that the encodings sort in the same order as the tuples.
+//
+// The format is the one used by the FoundationDB tuple layer.
+// Each element is written as a type code followed by an encoding of its value.
+// Elements of different types are ordered by their type code,
+// and a tuple sorts before any longer tuple which it is a prefix of.
+//
+// The supported element types are:
+//   - nil
+//   - []byte, and byte arrays such as cadata.ID, which are decoded as []byte
+//   - string
+//   - int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, which are decoded as int64,
+//     or uint64 if the value does not fit in an int64.
+//   - float32, float64
+//   - bool
+//   - time.Time, with nanosecond precision, which is decoded in UTC.
+//     It is written as the Unix time in seconds followed by the nanoseconds, so times far outside the range of UnixNano are supported.
+//   - Tuple, for nested tuples
+package tuple
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"go.brendoncarroll.net/state"
+	"go.brendoncarroll.net/state/kv"
+)
+
+const (
+	codeNil     = 0x00
+	codeBytes   = 0x01
+	codeString  = 0x02
+	codeNested  = 0x05
+	codeIntZero = 0x14
+	codeFloat32 = 0x20
+	codeFloat64 = 0x21
+	codeFalse   = 0x26
+	codeTrue    = 0x27
+	// codeTime is in the range which FoundationDB reserves for application types.
+	codeTime = 0x40
+	// codeEnd is greater than every other code.
+	// It is only used to bound PrefixSpans, and is never decoded.
+	codeEnd = 0xff
+)
+
+// Tuple is an ordered list of elements.
+// See the package documentation for the supported element types.
+type Tuple []any
+
+// Pack returns the encoding of t.
+// Pack panics if t contains an element of an unsupported type.
+func (t Tuple) Pack() []byte {
+	return Append(nil, t)
+}
+
+// Append appends the encoding of t to out and returns the result.
+// Append panics if t contains an element of an unsupported type.
+func Append(out []byte, t Tuple) []byte {
+	for _, x := range t {
+		out = appendElement(out, x, false)
+	}
+	return out
+}
+
+// Unpack parses the encoding of a Tuple.
+func Unpack(data []byte) (Tuple, error) {
+	ret := Tuple{}
+	for len(data) > 0 {
+		x, rest, err := readElement(data, false)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, x)
+		data = rest
+	}
+	return ret, nil
+}
+
+// Compare orders tuples by their encodings.
+func Compare(a, b Tuple) int {
+	return bytes.Compare(a.Pack(), b.Pack())
+}
+
+// PrefixSpan returns a Span containing prefix, and every Tuple which starts with the elements of prefix.
+func PrefixSpan(prefix Tuple) state.Span[Tuple] {
+	end := append(append(Tuple{}, prefix...), endMarker{})
+	return state.TotalSpan[Tuple]().WithLowerIncl(prefix).WithUpperExcl(end)
+}
+
+// endMarker is encoded as codeEnd, which sorts after every element.
+type endMarker struct{}
+
+var _ kv.Codec[Tuple] = Codec{}
+
+// Codec is a kv.Codec for Tuples. It preserves order.
+type Codec struct{}
+
+func (Codec) Encode(out []byte, x Tuple) []byte {
+	return Append(out, x)
+}
+
+func (Codec) Decode(data []byte) (Tuple, error) {
+	return Unpack(data)
+}
+
+func appendElement(out []byte, x any, nested bool) []byte {
+	switch x := x.(type) {
+	case nil:
+		if nested {
+			return append(out, codeNil, 0xff)
+		}
+		return append(out, codeNil)
+	case []byte:
+		return appendBytes(append(out, codeBytes), x)
+	case string:
+		return appendBytes(append(out, codeString), []byte(x))
+	case int:
+		return appendInt(out, int64(x))
+	case int8:
+		return appendInt(out, int64(x))
+	case int16:
+		return appendInt(out, int64(x))
+	case int32:
+		return appendInt(out, int64(x))
+	case int64:
+		return appendInt(out, x)
+	case uint:
+		return appendUint(out, uint64(x))
+	case uint8:
+		return appendUint(out, uint64(x))
+	case uint16:
+		return appendUint(out, uint64(x))
+	case uint32:
+		return appendUint(out, uint64(x))
+	case uint64:
+		return appendUint(out, x)
+	case float32:
+		bits := math.Float32bits(x)
+		if bits&(1<<31) != 0 {
+			bits = ^bits
+		} else {
+			bits ^= 1 << 31
+		}
+		var buf [4]byte
+		binary.BigEndian.PutUint32(buf[:], bits)
+		return append(append(out, codeFloat32), buf[:]...)
+	case float64:
+		bits := math.Float64bits(x)
+		if bits&(1<<63) != 0 {
+			bits = ^bits
+		} else {
+			bits ^= 1 << 63
+		}
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], bits)
+		return append(append(out, codeFloat64), buf[:]...)
+	case bool:
+		if x {
+			return append(out, codeTrue)
+		}
+		return append(out, codeFalse)
+	case time.Time:
+		var buf [12]byte
+		binary.BigEndian.PutUint64(buf[:8], uint64(x.Unix())^(1<<63))
+		binary.BigEndian.PutUint32(buf[8:], uint32(x.Nanosecond()))
+		return append(append(out, codeTime), buf[:]...)
+	case Tuple:
+		out = append(out, codeNested)
+		for _, y := range x {
+			out = appendElement(out, y, true)
+		}
+		return append(out, 0x00)
+	case endMarker:
+		return append(out, codeEnd)
+	}
+	rv := reflect.ValueOf(x)
+	if rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8 {
+		b := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(b), rv)
+		return appendBytes(append(out, codeBytes), b)
+	}
+	panic(fmt.Sprintf("tuple: unsupported element type %T", x))
+}
+
+// appendBytes writes x with each 0x00 escaped as 0x00 0xff, followed by a terminating 0x00.
+func appendBytes(out []byte, x []byte) []byte {
+	for _, b := range x {
+		out = append(out, b)
+		if b == 0x00 {
+			out = append(out, 0xff)
+		}
+	}
+	return append(out, 0x00)
+}
+
+// appendInt writes x as a code giving its sign and length, followed by its magnitude in as few bytes as possible.
+// Negative numbers are written as the ones' complement of their magnitude, so that they sort in the right order.
+func appendInt(out []byte, x int64) []byte {
+	if x >= 0 {
+		return appendUint(out, uint64(x))
+	}
+	mag := uint64(-x)
+	n := intLen(mag)
+	out = append(out, byte(codeIntZero-n))
+	return appendBigEndian(out, ^mag, n)
+}
+
+func appendUint(out []byte, x uint64) []byte {
+	n := intLen(x)
+	out = append(out, byte(codeIntZero+n))
+	return appendBigEndian(out, x, n)
+}
+
+// intLen returns the number of bytes needed to hold x.
+func intLen(x uint64) int {
+	var n int
+	for x > 0 {
+		n++
+		x >>= 8
+	}
+	return n
+}
+
+// appendBigEndian writes the low n bytes of x.
+func appendBigEndian(out []byte, x uint64, n int) []byte {
+	for i := n - 1; i >= 0; i-- {
+		out = append(out, byte(x>>(8*i)))
+	}
+	return out
+}
+
+var errTruncated = errors.New("tuple: truncated data")
+
+// readElement parses one element from the front of data, and returns the rest.
+func readElement(data []byte, nested bool) (any, []byte, error) {
+	code := data[0]
+	data = data[1:]
+	switch {
+	case code == codeNil:
+		if nested {
+			if len(data) == 0 || data[0] != 0xff {
+				return nil, nil, errors.New("tuple: unescaped nil in nested tuple")
+			}
+			data = data[1:]
+		}
+		return nil, data, nil
+	case code == codeBytes:
+		return readBytes(data)
+	case code == codeString:
+		b, rest, err := readBytes(data)
+		return string(b), rest, err
+	case code >= codeIntZero-8 && code <= codeIntZero+8:
+		return readInt(code, data)
+	case code == codeFloat32:
+		if len(data) < 4 {
+			return nil, nil, errTruncated
+		}
+		bits := binary.BigEndian.Uint32(data)
+		if bits&(1<<31) != 0 {
+			bits ^= 1 << 31
+		} else {
+			bits = ^bits
+		}
+		return math.Float32frombits(bits), data[4:], nil
+	case code == codeFloat64:
+		if len(data) < 8 {
+			return nil, nil, errTruncated
+		}
+		bits := binary.BigEndian.Uint64(data)
+		if bits&(1<<63) != 0 {
+			bits ^= 1 << 63
+		} else {
+			bits = ^bits
+		}
+		return math.Float64frombits(bits), data[8:], nil
+	case code == codeFalse:
+		return false, data, nil
+	case code == codeTrue:
+		return true, data, nil
+	case code == codeTime:
+		if len(data) < 12 {
+			return nil, nil, errTruncated
+		}
+		secs := int64(binary.BigEndian.Uint64(data) ^ (1 << 63))
+		nanos := binary.BigEndian.Uint32(data[8:])
+		if nanos >= 1e9 {
+			return nil, nil, errors.New("tuple: time nanoseconds out of range")
+		}
+		return time.Unix(secs, int64(nanos)).UTC(), data[12:], nil
+	case code == codeNested:
+		ret := Tuple{}
+		for {
+			if len(data) == 0 {
+				return nil, nil, errTruncated
+			}
+			if data[0] == 0x00 && (len(data) == 1 || data[1] != 0xff) {
+				return ret, data[1:], nil
+			}
+			x, rest, err := readElement(data, true)
+			if err != nil {
+				return nil, nil, err
+			}
+			ret = append(ret, x)
+			data = rest
+		}
+	default:
+		return nil, nil, fmt.Errorf("tuple: unknown type code %#x", code)
+	}
+}
+
+// readBytes reads an escaped byte string, up to and including its terminator.
+func readBytes(data []byte) ([]byte, []byte, error) {
+	var ret []byte
+	for i := 0; i < len(data); i++ {
+		if data[i] != 0x00 {
+			ret = append(ret, data[i])
+			continue
+		}
+		if i+1 < len(data) && data[i+1] == 0xff {
+			ret = append(ret, 0x00)
+			i++
+			continue
+		}
+		if ret == nil {
+			ret = []byte{}
+		}
+		return ret, data[i+1:], nil
+	}
+	return nil, nil, errTruncated
+}
+
+func readInt(code byte, data []byte) (any, []byte, error) {
+	n := int(code) - codeIntZero
+	neg := n < 0
+	if neg {
+		n = -n
+	}
+	if len(data) < n {
+		return nil, nil, errTruncated
+	}
+	var x uint64
+	for _, b := range data[:n] {
+		x = x<<8 | uint64(b)
+	}
+	data = data[n:]
+	if !neg {
+		if x > math.MaxInt64 {
+			return x, data, nil
+		}
+		return int64(x), data, nil
+	}
+	mag := ^x
+	if n < 8 {
+		mag &= 1<<(8*n) - 1
+	}
+	if mag > 1<<63 {
+		return nil, nil, errors.New("tuple: integer out of range")
+	}
+	return -int64(mag), data, nil
+}
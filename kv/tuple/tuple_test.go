@@ -0,0 +1,157 @@
+package tuple
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go.brendoncarroll.net/state"
+	"go.brendoncarroll.net/state/kv"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var id [32]byte
+	id[0], id[31] = 0xab, 0x00
+	now := time.Unix(1700000000, 123456789).UTC()
+	// just outside the range of UnixNano.
+	timeMin := time.Unix(0, math.MinInt64).Add(-1).UTC()
+	timeMax := time.Unix(0, math.MaxInt64).Add(1).UTC()
+	for _, tc := range []struct {
+		In, Out Tuple
+	}{
+		{Tuple{}, Tuple{}},
+		{Tuple{nil}, Tuple{nil}},
+		{Tuple{[]byte{}, []byte{0, 1, 0}, "", "a\x00b"}, Tuple{[]byte{}, []byte{0, 1, 0}, "", "a\x00b"}},
+		{Tuple{0, 1, -1, 255, -256, int8(-3), uint16(7)}, Tuple{int64(0), int64(1), int64(-1), int64(255), int64(-256), int64(-3), int64(7)}},
+		{Tuple{int64(math.MinInt64), int64(math.MaxInt64), uint64(math.MaxUint64)}, Tuple{int64(math.MinInt64), int64(math.MaxInt64), uint64(math.MaxUint64)}},
+		{Tuple{1.5, -2.25, float32(3), math.Inf(-1)}, Tuple{1.5, -2.25, float32(3), math.Inf(-1)}},
+		{Tuple{true, false}, Tuple{true, false}},
+		{Tuple{now}, Tuple{now}},
+		{Tuple{timeMin, timeMax}, Tuple{timeMin, timeMax}},
+		{Tuple{id}, Tuple{id[:]}},
+		{Tuple{"a", Tuple{nil, 1, Tuple{}}, nil}, Tuple{"a", Tuple{nil, int64(1), Tuple{}}, nil}},
+	} {
+		actual, err := Unpack(tc.In.Pack())
+		require.NoError(t, err)
+		require.Equal(t, tc.Out, actual)
+	}
+}
+
+func TestOrder(t *testing.T) {
+	// each tuple must sort strictly before the next.
+	sorted := []Tuple{
+		{},
+		{nil},
+		{[]byte{}},
+		{[]byte{0}},
+		{[]byte{0, 0}},
+		{[]byte{1}},
+		{""},
+		{"a"},
+		{"a", nil},
+		{"a", "b"},
+		{"a\x00"},
+		{"ab"},
+		{"b"},
+		{Tuple{}},
+		{Tuple{nil}},
+		{Tuple{1}},
+		{int64(math.MinInt64)},
+		{-65536},
+		{-256},
+		{-255},
+		{-1},
+		{0},
+		{1},
+		{255},
+		{256},
+		{int64(math.MaxInt64)},
+		{uint64(math.MaxUint64)},
+		{float32(-1)},
+		{float32(1)},
+		{math.Inf(-1)},
+		{-1.5},
+		{math.Copysign(0, -1)},
+		{0.0},
+		{1e-9},
+		{2.5},
+		{math.Inf(1)},
+		{false},
+		{true},
+		{time.Date(1600, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Unix(0, math.MinInt64).Add(-1)},
+		{time.Unix(0, math.MinInt64)},
+		{time.Unix(-1, 0)},
+		{time.Unix(0, 0)},
+		{time.Unix(0, 1)},
+		{time.Unix(1700000000, 0)},
+		{time.Unix(0, math.MaxInt64)},
+		{time.Unix(0, math.MaxInt64).Add(1)},
+		{time.Date(2300, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for i := 1; i < len(sorted); i++ {
+		require.Equal(t, -1, Compare(sorted[i-1], sorted[i]), "%v < %v", sorted[i-1], sorted[i])
+		require.Equal(t, 1, Compare(sorted[i], sorted[i-1]))
+	}
+	require.Equal(t, 0, Compare(Tuple{1, "a"}, Tuple{int64(1), "a"}))
+}
+
+func TestUnpackErrors(t *testing.T) {
+	for _, data := range [][]byte{
+		{codeBytes, 'a'},
+		{codeString},
+		{codeIntZero + 2, 1},
+		{codeFloat64, 0, 0},
+		{codeTime, 0x80, 0, 0, 0, 0, 0, 0, 0},
+		{codeTime, 0x80, 0, 0, 0, 0, 0, 0, 0, 0x3b, 0x9a, 0xca, 0x00},
+		{codeNested, codeNil, 0xff},
+		{codeEnd},
+		{0x99},
+	} {
+		_, err := Unpack(data)
+		require.Error(t, err, "%x", data)
+	}
+}
+
+func TestPrefixSpan(t *testing.T) {
+	ctx := context.Background()
+	s := kv.NewEncoded[Tuple, string](kv.NewMemStore[[]byte, []byte](bytes.Compare), Codec{}, kv.StringCodec{})
+	keys := []Tuple{
+		{"users"},
+		{"users", 1, "name"},
+		{"users", 1, "email"},
+		{"users", 2, "name"},
+		{"users", 10, "name"},
+		{"usersx", 1},
+		{"groups", 1},
+	}
+	for _, k := range keys {
+		require.NoError(t, s.Put(ctx, k, ""))
+	}
+	list := func(span state.Span[Tuple]) (ret []Tuple) {
+		err := kv.ForEachEntry[Tuple, string](ctx, s, span, func(ent kv.Entry[Tuple, string]) error {
+			ret = append(ret, ent.Key)
+			return nil
+		})
+		require.NoError(t, err)
+		return ret
+	}
+	i := func(x int) int64 { return int64(x) }
+	require.Equal(t, []Tuple{
+		{"users"},
+		{"users", i(1), "email"},
+		{"users", i(1), "name"},
+		{"users", i(2), "name"},
+		{"users", i(10), "name"},
+	}, list(PrefixSpan(Tuple{"users"})))
+	require.Equal(t, []Tuple{
+		{"users", i(1), "name"},
+		{"users", i(1), "email"},
+	}, list(PrefixSpan(Tuple{"users", 1}).Desc()))
+	require.Empty(t, list(PrefixSpan(Tuple{"users", 3})))
+	require.Len(t, list(PrefixSpan(Tuple{})), len(keys))
+}
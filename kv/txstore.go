@@ -0,0 +1,67 @@
+package kv
+
+import (
+	"context"
+
+	"go.brendoncarroll.net/state"
+)
+
+var _ Store[struct{}, struct{}] = txStore[struct{}, struct{}]{}
+var _ EntryLister[struct{}, struct{}] = txStore[struct{}, struct{}]{}
+
+type txStore[K, V any] struct {
+	x StoreTx[K, V]
+}
+
+// FromStoreTx returns a Store which performs each operation in its own transaction on x.
+func FromStoreTx[K, V any](x StoreTx[K, V]) Store[K, V] {
+	return txStore[K, V]{x: x}
+}
+
+func (s txStore[K, V]) Put(ctx context.Context, k K, v V) error {
+	return s.x.Modify(ctx, func(tx Store[K, V]) error {
+		return tx.Put(ctx, k, v)
+	})
+}
+
+func (s txStore[K, V]) Delete(ctx context.Context, k K) error {
+	return s.x.Modify(ctx, func(tx Store[K, V]) error {
+		return tx.Delete(ctx, k)
+	})
+}
+
+// DeleteSpan removes the keys in span in a single transaction.
+func (s txStore[K, V]) DeleteSpan(ctx context.Context, span state.Span[K]) (n int, err error) {
+	err = s.x.Modify(ctx, func(tx Store[K, V]) error {
+		n, err = DeleteSpan[K](ctx, tx, span)
+		return err
+	})
+	return n, err
+}
+
+func (s txStore[K, V]) Get(ctx context.Context, k K, dst *V) error {
+	return s.x.View(ctx, func(tx ReadOnlyStore[K, V]) error {
+		return tx.Get(ctx, k, dst)
+	})
+}
+
+func (s txStore[K, V]) List(ctx context.Context, span state.Span[K], ks []K) (n int, err error) {
+	err = s.x.View(ctx, func(tx ReadOnlyStore[K, V]) error {
+		n, err = tx.List(ctx, span, ks)
+		return err
+	})
+	return n, err
+}
+
+// ListEntries reads the entries in span in a single read-only transaction.
+func (s txStore[K, V]) ListEntries(ctx context.Context, span state.Span[K], buf []Entry[K, V]) (n int, err error) {
+	err = s.x.View(ctx, func(tx ReadOnlyStore[K, V]) error {
+		n, err = ListEntries(ctx, tx, span, buf)
+		return err
+	})
+	return n, err
+}
+
+func (s txStore[K, V]) Exists(ctx context.Context, k K) (bool, error) {
+	return ExistsUsingList[K](ctx, s, k)
+}
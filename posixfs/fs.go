@@ -12,6 +12,7 @@ const Sep = "/"
 const (
 	O_RDONLY = os.O_RDONLY
 	O_WRONLY = os.O_WRONLY
+	O_RDWR   = os.O_RDWR
 	O_EXCL   = os.O_EXCL
 	O_APPEND = os.O_APPEND
 	O_CREATE = os.O_CREATE
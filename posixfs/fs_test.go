@@ -4,10 +4,13 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"path"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"go.brendoncarroll.net/state"
 )
 
 func TestPutFile(t *testing.T) {
@@ -37,3 +40,24 @@ func TestDeleteFile(t *testing.T) {
 	_, err = x.Stat(testPath)
 	require.True(t, errors.Is(err, ErrNotExist))
 }
+
+func TestWalkLeavesSpan(t *testing.T) {
+	ctx := context.Background()
+	x := NewTestFS(t)
+	paths := []string{"a/1", "a/2", "b", "c/d/1", "c/d/2", "c/e"}
+	for _, p := range paths {
+		require.NoError(t, MkdirAll(x, path.Dir(p), 0o755))
+		require.NoError(t, PutFile(ctx, x, p, 0o644, bytes.NewBufferString(p)))
+	}
+	walk := func(span state.Span[string]) (ret []string) {
+		err := WalkLeavesSpan(ctx, x, "", span, func(p string, _ DirEnt) error {
+			ret = append(ret, p)
+			return nil
+		})
+		require.NoError(t, err)
+		return ret
+	}
+	require.Equal(t, paths, walk(state.TotalSpan[string]()))
+	require.Equal(t, []string{"c/e", "c/d/2", "c/d/1", "b", "a/2", "a/1"}, walk(state.DescSpan[string]()))
+	require.Equal(t, []string{"c/d/1", "b", "a/2"}, walk(state.DescSpan[string]().WithLowerExcl("a/1").WithUpperIncl("c/d/1")))
+}
@@ -152,7 +152,7 @@ func WalkLeaves(ctx context.Context, x FS, p string, fn func(string, DirEnt) err
 }
 
 // WalkLeavesSpan walks the leaves in x which are contained in the span.
-// WalkLeavesSpan emits paths in sorted order.
+// WalkLeavesSpan emits paths in sorted order, which is descending if span.IsDesc().
 func WalkLeavesSpan(ctx context.Context, x FS, p string, span state.Span[string], fn func(string, DirEnt) error) error {
 	f, err := x.OpenFile(p, O_RDONLY, 0)
 	if err != nil {
@@ -174,6 +174,9 @@ func WalkLeavesSpan(ctx context.Context, x FS, p string, span state.Span[string]
 		return err
 	}
 	sort.Slice(dirEnts, func(i, j int) bool {
+		if span.IsDesc() {
+			return dirEnts[i].Name > dirEnts[j].Name
+		}
 		return dirEnts[i].Name < dirEnts[j].Name
 	})
 	for _, dirEnt := range dirEnts {
@@ -7,6 +7,7 @@ import (
 
 // Span is a specification for iteration through values of type T.
 // It includes begin and end bounds, which can be inclusive or exclusive of the bounding value.
+// A Span is iterated in ascending order, unless it is descending, see Desc.
 // The empty Span is equivalent to TotalSpan() and includes all elements of type T.
 type Span[T any] struct {
 	lower, upper T
@@ -18,6 +19,7 @@ const (
 	spanModeIncludesLower
 	spanModeHasUpper
 	spanModeIncludesUpper
+	spanModeDesc
 )
 
 // TotalSpan returns a Span[T] which contains all elements of T
@@ -30,6 +32,11 @@ func PointSpan[T any](x T) Span[T] {
 	return Span[T]{}.WithLowerIncl(x).WithUpperIncl(x)
 }
 
+// DescSpan returns a Span[T] which contains all elements of T, in descending order.
+func DescSpan[T any]() Span[T] {
+	return Span[T]{}.Desc()
+}
+
 // WithLowerIncl returns a copy of s with an inclusive lower bound
 func (s Span[T]) WithLowerIncl(x T) Span[T] {
 	s.lower = x
@@ -88,9 +95,33 @@ func (s Span[T]) UpperBound() (T, bool) {
 	return s.upper, ok
 }
 
+// Desc returns a copy of s, which is iterated in descending order.
+// The bounds are unchanged.
+func (s Span[T]) Desc() Span[T] {
+	s.mode |= spanModeDesc
+	return s
+}
+
+// Asc returns a copy of s, which is iterated in ascending order.
+// The bounds are unchanged.
+func (s Span[T]) Asc() Span[T] {
+	s.mode &= ^spanModeDesc
+	return s
+}
+
+// IsDesc returns true if the Span is iterated in descending order.
 func (s Span[T]) IsDesc() bool {
-	// TODO: descending Span's not yet supported.
-	return false
+	return s.mode&spanModeDesc > 0
+}
+
+// After returns the part of s which comes after x in the order of iteration.
+// For an ascending Span that is everything above x, and for a descending Span everything below x.
+// It is used to continue listing from the last element seen.
+func (s Span[T]) After(x T) Span[T] {
+	if s.IsDesc() {
+		return s.WithUpperExcl(x)
+	}
+	return s.WithLowerExcl(x)
 }
 
 // IncludesLower returns true if there is a lower bound and it is inclusive
@@ -156,5 +187,8 @@ func (s Span[T]) String() string {
 	} else {
 		sb.WriteString("max)")
 	}
+	if s.IsDesc() {
+		sb.WriteString(" desc")
+	}
 	return sb.String()
 }
@@ -33,7 +33,7 @@ func TestByteSpan(t *testing.T) {
 	})
 }
 
-func ExampleSpanString() {
+func ExampleSpan_String() {
 	fmt.Println(Span[int]{})
 
 	a := Span[int]{}.WithLowerIncl(-5)
@@ -45,9 +45,13 @@ func ExampleSpanString() {
 	c := Span[int]{}.WithLowerIncl(2).WithUpperExcl(20)
 	fmt.Println(c)
 
+	d := DescSpan[int]().WithUpperIncl(7)
+	fmt.Println(d)
+
 	// Output:
 	// (min, max)
 	// [-5, max)
 	// (min, 10)
 	// [2, 20)
+	// (min, 7] desc
 }